@@ -0,0 +1,123 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// goValueFlags are the flags of ‘go test’ (and ‘go build’) that take a
+// separate value, i.e. that can be given as ‘-flag value’.
+var goValueFlags = map[string]bool{
+	"asmflags": true, "bench": true, "benchtime": true, "blockprofile": true,
+	"blockprofilerate": true, "count": true, "coverpkg": true, "covermode": true,
+	"coverprofile": true, "cpu": true, "cpuprofile": true, "exec": true,
+	"fuzz": true, "fuzzminimizetime": true, "fuzztime": true, "gccgoflags": true,
+	"gcflags": true, "installsuffix": true, "ldflags": true, "list": true,
+	"memprofile": true, "memprofilerate": true, "mod": true, "modfile": true,
+	"mutexprofile": true, "mutexprofilefraction": true, "o": true,
+	"outputdir": true, "overlay": true, "p": true, "parallel": true, "pgo": true,
+	"pkgdir": true, "run": true, "shuffle": true, "skip": true, "tags": true,
+	"test.run": true, "timeout": true, "toolexec": true, "trace": true, "vet": true,
+	"C": true,
+}
+
+// splitArgs splits the arguments for ‘go test’ into its flags, the
+// packages, and whatever comes after ‘-args’ (including ‘-args’ itself).
+func splitArgs(args []string) (flags, pkgs, rest []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-args" || arg == "--args" {
+			rest = args[i:]
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			pkgs = append(pkgs, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.IndexByte(name, '=') == -1 && goValueFlags[name] && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return flags, pkgs, rest
+}
+
+// flagValue returns the value given to the named ‘go test’ flag, if any
+// (the last one wins, as with go).
+func flagValue(flags []string, name string) (string, bool) {
+	value, found := "", false
+	for i := 0; i < len(flags); i++ {
+		arg := strings.TrimLeft(flags[i], "-")
+		if idx := strings.IndexByte(arg, '='); idx > -1 {
+			if arg[:idx] == name {
+				value, found = arg[idx+1:], true
+			}
+			continue
+		}
+		if arg == name && goValueFlags[name] && i+1 < len(flags) {
+			i++
+			value, found = flags[i], true
+		}
+	}
+	return value, found
+}
+
+// profileFlags are the ‘go test’ flags that ask for a profile to be
+// written, and what sort of profile each one is.
+var profileFlags = []struct{ name, what string }{
+	{"cpuprofile", "CPU"},
+	{"memprofile", "Memory"},
+	{"blockprofile", "Block"},
+	{"mutexprofile", "Mutex"},
+}
+
+// profileHints says where the profiles asked for in the flags were
+// written, and how to look at them.
+func profileHints(flags []string) []string {
+	dir, _ := flagValue(flags, "outputdir")
+	var hints []string
+	for _, p := range profileFlags {
+		file, ok := flagValue(flags, p.name)
+		if !ok || file == "" {
+			continue
+		}
+		if dir != "" && !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		hints = append(hints, fmt.Sprintf("%s profile written to %s; view it with ‘go tool pprof %s’.", p.what, file, shellQuote([]string{file})))
+	}
+	return hints
+}
+
+// serialHints points out when the flags made things run one at a time
+// that could've run side by side, and it looks like that cost something.
+func serialHints(flags []string, ss *summary) []string {
+	var hints []string
+	wall := ss.last.Sub(ss.first).Seconds()
+	if p, _ := flagValue(flags, "p"); p == "1" && ss.packages.total > 1 && wall > 2*ss.slowest && wall-ss.slowest > 5 {
+		hints = append(hints, fmt.Sprintf("with ‘-p 1’ packages were tested one at a time, taking %s; side by side it could have been closer to %s.",
+			secs(wall), secs(ss.slowest)))
+	}
+	if par, _ := flagValue(flags, "parallel"); par == "1" && ss.paused > 1 {
+		hints = append(hints, fmt.Sprintf("%s called t.Parallel, but with ‘-parallel 1’ they ran one at a time anyway.",
+			gn("test", "tests")(ss.paused)))
+	}
+	return hints
+}
+
+// secs formats a number of seconds for people.
+func secs(s float64) string {
+	return time.Duration(s * float64(time.Second)).Round(100 * time.Millisecond).String()
+}
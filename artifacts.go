@@ -0,0 +1,43 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// artifacts returns the paths a test said it left behind, on lines with
+// the given marker (e.g. ‘ARTIFACT:’) followed by the path.
+func artifacts(marker string, output []string) []string {
+	var paths []string
+	for _, line := range output {
+		idx := strings.Index(line, marker)
+		if idx < 0 {
+			continue
+		}
+		if path := strings.TrimSpace(line[idx+len(marker):]); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// artifactsLine lists the paths as links to the files, for the catalogue
+// of failures. Paths that aren't absolute can't be linked to, as they're
+// relative to wherever the test ran, so they're listed as they are.
+func artifactsLine(paths []string, esc *escape) string {
+	links := make([]string, len(paths))
+	for i, path := range paths {
+		if !filepath.IsAbs(path) {
+			links[i] = path
+			continue
+		}
+		u := url.URL{Scheme: "file", Path: path}
+		links[i] = esc.uri(u.String(), filepath.Base(path))
+	}
+	return "    artifacts: " + strings.Join(links, ", ") + "\n"
+}
@@ -0,0 +1,28 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestArtifacts(t *testing.T) {
+	output := []string{
+		"=== RUN   TestShot\n",
+		"    shot_test.go:12: ARTIFACT: /tmp/screen shot.png\n",
+		"    shot_test.go:13: ARTIFACT: out/log.txt\n",
+		"    shot_test.go:14: ARTIFACT:\n",
+		"--- FAIL: TestShot (0.00s)\n",
+	}
+	paths := artifacts("ARTIFACT:", output)
+	if expected := []string{"/tmp/screen shot.png", "out/log.txt"}; !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("expected %q, got %q", expected, paths)
+	}
+	line := artifactsLine(paths, escapes[testEsc])
+	if expected := "    artifacts: [screen shot.png](file:///tmp/screen%20shot.png), out/log.txt\n"; line != expected {
+		t.Errorf("expected %q, got %q", expected, line)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// a baseline is a list of tests that are known to fail (quarantined).
+// Tests in the baseline that fail are expected failures (‘xfail’),
+// and tests in the baseline that pass are unexpectedly passing
+// (‘xpass’) and should probably be taken out of the baseline.
+type baseline struct {
+	names map[string]bool
+	// tests that failed as expected, as package:test
+	xfailed map[string]bool
+	// packages with expected failures
+	expected map[string]bool
+	// number of unexpected failures, per package
+	unexpected map[string]int
+	// tests from the baseline that passed
+	xpassed []string
+}
+
+// loadBaseline reads a baseline file: one test name per line, as
+// goctest shows them (but the untrimmed package path also works).
+// Blank lines and lines starting with ‘#’ are ignored.
+func loadBaseline(path string) (*baseline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b := &baseline{
+		names:      map[string]bool{},
+		xfailed:    map[string]bool{},
+		expected:   map[string]bool{},
+		unexpected: map[string]int{},
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		b.names[line] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *baseline) has(ev *TestEvent) bool {
+	return b.names[ev.name()] || b.names[ev.Package+":"+ev.Test]
+}
+
+// hasFailedChild returns whether a subtest of the given test failed as
+// expected (in which case the test itself failing is expected too).
+func (b *baseline) hasFailedChild(ev *TestEvent) bool {
+	parent := ev.Package + ":" + ev.Test + "/"
+	for name := range b.xfailed {
+		if strings.HasPrefix(name, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+// adjust rewrites the event's action if the baseline has an opinion
+// about it. A test or package whose only failures were expected is
+// itself an expected failure.
+func (b *baseline) adjust(ev *TestEvent) {
+	if b == nil {
+		return
+	}
+	if ev.Test == "" {
+		if ev.Action == "fail" && b.expected[ev.Package] && b.unexpected[ev.Package] == 0 {
+			ev.Action = "xfail"
+		}
+		return
+	}
+	if !ev.isTest() {
+		return
+	}
+	switch ev.Action {
+	case "pass":
+		if b.has(ev) {
+			ev.Action = "xpass"
+			b.xpassed = append(b.xpassed, ev.name())
+		}
+	case "fail":
+		if b.has(ev) || b.hasFailedChild(ev) {
+			ev.Action = "xfail"
+			b.xfailed[ev.Package+":"+ev.Test] = true
+			b.expected[ev.Package] = true
+		} else {
+			b.unexpected[ev.Package]++
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+var benchRx = regexp.MustCompile(`^Benchmark\S*\s+(\d+)\s+(.*\S)\s*$`)
+
+type benchmark struct {
+	name   string
+	runs   string
+	nsOp   string
+	bOp    string
+	allocs string
+}
+
+// benchmarks collects the results of benchmarks as they go by, to
+// show them all together at the end.
+type benchmarks []benchmark
+
+// parse looks for a benchmark result in the event's output. The
+// result is made up of the number of runs followed by value-unit
+// pairs; go has been adding units over time so anything not known is
+// ignored.
+func (bs *benchmarks) parse(ev *TestEvent) {
+	if ev.Action != "output" && ev.Action != "bench" {
+		return
+	}
+	m := benchRx.FindStringSubmatch(ev.Output)
+	if m == nil {
+		return
+	}
+	b := benchmark{name: ev.name(), runs: m[1]}
+	fields := strings.Fields(m[2])
+	for i := 0; i+1 < len(fields); i += 2 {
+		switch fields[i+1] {
+		case "ns/op":
+			b.nsOp = fields[i]
+		case "B/op":
+			b.bOp = fields[i]
+		case "allocs/op":
+			b.allocs = fields[i]
+		}
+	}
+	if b.nsOp == "" {
+		// not what we thought it was
+		return
+	}
+	*bs = append(*bs, b)
+}
+
+func (bs benchmarks) report(w io.Writer, esc *escape) {
+	if len(bs) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, esc.nope+"Benchmark\tRuns\tns/op\tB/op\tallocs/op\t"+esc.endc)
+	for _, b := range bs {
+		bOp, allocs := b.bOp, b.allocs
+		if bOp == "" {
+			bOp = "-"
+		}
+		if allocs == "" {
+			allocs = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", b.name, b.runs, b.nsOp, bOp, allocs)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,59 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import "fmt"
+
+// breadcrumbs remembers the last few tests to finish in each package,
+// so that the first test to fail can say what ran just before it (for
+// when one test leaves a mess that trips up another). A nil breadcrumbs
+// does nothing.
+type breadcrumbs struct {
+	n      int
+	recent map[string][]string
+	done   bool
+}
+
+func newBreadcrumbs(n int) *breadcrumbs {
+	if n <= 0 {
+		return nil
+	}
+	return &breadcrumbs{n: n, recent: map[string][]string{}}
+}
+
+// add notes the test as having finished.
+func (b *breadcrumbs) add(ev *TestEvent) {
+	if b == nil || !ev.isTest() {
+		return
+	}
+	switch ev.Action {
+	case "pass", "fail", "skip", "xfail", "xpass", "flaky":
+	default:
+		return
+	}
+	recent := append(b.recent[ev.Package], ev.name())
+	if len(recent) > b.n {
+		recent = recent[len(recent)-b.n:]
+	}
+	b.recent[ev.Package] = recent
+}
+
+// before returns the lines to show above the output of the given failed
+// test, if it's the first one to fail.
+func (b *breadcrumbs) before(ev *TestEvent, esc *escape) []string {
+	if b == nil || b.done || ev.Action != "fail" || !ev.isTest() {
+		return nil
+	}
+	b.done = true
+	recent := b.recent[ev.Package]
+	if len(recent) == 0 {
+		return []string{fmt.Sprintf("%s(%s was the first test to finish in its package)%s\n", esc.skip, ev.name(), esc.endc)}
+	}
+	lines := []string{fmt.Sprintf("%sBefore %s failed, these finished in its package:%s\n", esc.skip, ev.name(), esc.endc)}
+	for _, name := range recent {
+		lines = append(lines, fmt.Sprintf("%s  %s%s\n", esc.skip, name, esc.endc))
+	}
+	return lines
+}
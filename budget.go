@@ -0,0 +1,87 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+const droppedMarker = "[earlier output dropped]\n"
+
+// an outputBudget keeps the amount of test output goctest holds on to
+// in check, so a pathological run doesn't eat all the memory.
+type outputBudget struct {
+	max  int
+	used int
+}
+
+func (b *outputBudget) over() bool {
+	return b.max > 0 && b.used > b.max
+}
+
+// hold accounts for a line of output being held on to.
+func (b *outputBudget) hold(line string) {
+	b.used += len(line)
+}
+
+// release accounts for lines of output that are no longer held on to.
+func (b *outputBudget) release(lines []string) {
+	for _, line := range lines {
+		b.used -= len(line)
+	}
+}
+
+// forget accounts for the given number of bytes of output no longer
+// being held on to.
+func (b *outputBudget) forget(n int) {
+	b.used -= n
+}
+
+// trim drops lines from the front of the given output until the budget
+// is no longer exceeded (or there's nothing left to drop), leaving a
+// marker in their place (which counts against the budget too).
+func (b *outputBudget) trim(lines []string) []string {
+	if !b.over() {
+		return lines
+	}
+	start, marker := 0, len(droppedMarker)
+	if len(lines) > 0 && lines[0] == droppedMarker {
+		// already accounted for
+		start, marker = 1, 0
+	}
+	i := start
+	for ; b.used+marker > b.max && i < len(lines); i++ {
+		b.used -= len(lines[i])
+	}
+	if i == start {
+		return lines
+	}
+	// the marker takes the place of the last line dropped
+	i--
+	lines[i] = droppedMarker
+	b.used += marker
+	return lines[i:]
+}
+
+// keep moves a failed test's output over to the failures: what was held
+// of it as it came is let go of, and what's to be shown of it (which
+// can be more, or less, e.g. once highlighted) is held instead.
+func (b *outputBudget) keep(fails, held, shown []string) []string {
+	b.release(held)
+	return b.join(fails, shown)
+}
+
+// join appends more output to the given output, holding on to it and
+// dropping the oldest of it if that's over budget. Back-to-back markers
+// are avoided.
+func (b *outputBudget) join(lines, more []string) []string {
+	if len(lines) > 0 && len(more) > 0 && lines[len(lines)-1] == droppedMarker && more[0] == droppedMarker {
+		more = more[1:]
+	}
+	for _, line := range more {
+		b.hold(line)
+	}
+	lines = append(lines, more...)
+	if b.over() {
+		lines = b.trim(lines)
+	}
+	return lines
+}
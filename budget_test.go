@@ -0,0 +1,87 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// what the budget says is held is what is held, whatever's done to the
+// output of the failures on the way
+func TestOutputBudget(t *testing.T) {
+	type failure struct {
+		held, shown []string
+	}
+	tests := []struct {
+		what     string
+		max      int
+		failures []failure
+		fails    []string
+	}{
+		{
+			what: "under budget",
+			max:  100,
+			failures: []failure{
+				{held: []string{"a1\n", "a2\n"}, shown: []string{"a1\n", "a2\n"}},
+				{held: []string{"b1\n"}, shown: []string{"b1\n"}},
+			},
+			fails: []string{"a1\n", "a2\n", "b1\n"},
+		}, {
+			what: "shown as held",
+			max:  60,
+			failures: []failure{
+				{held: []string{"aaaaaaaaa\n", "aaaaaaaaa\n"}, shown: []string{"aaaaaaaaa\n", "aaaaaaaaa\n"}},
+				{held: []string{"bbbbbbbbb\n", "bbbbbbbbb\n"}, shown: []string{"bbbbbbbbb\n", "bbbbbbbbb\n"}},
+				{held: []string{"ccccccccc\n"}, shown: []string{"ccccccccc\n"}},
+				{held: []string{"ddddddddd\n", "ddddddddd\n"}, shown: []string{"ddddddddd\n", "ddddddddd\n"}},
+			},
+			fails: []string{droppedMarker, "ccccccccc\n", "ddddddddd\n", "ddddddddd\n"},
+		}, {
+			what: "shown bigger than held",
+			max:  50,
+			failures: []failure{
+				{held: []string{"a\n"}, shown: []string{"»»»» a ««««\n"}},
+				{held: []string{"b\n"}, shown: []string{"»»»» b ««««\n"}},
+				{held: []string{"c\n"}, shown: []string{"»»»» c ««««\n"}},
+			},
+			fails: []string{droppedMarker, "»»»» c ««««\n"},
+		}, {
+			what: "shown smaller than held",
+			max:  30,
+			failures: []failure{
+				{held: []string{"aaaaaaaaaaaaaaaaaaaaaaaaa\n"}, shown: []string{"a\n"}},
+				{held: []string{"bbbbbbbbbbbbbbbbbbbbbbbbb\n"}, shown: []string{"b\n"}},
+				{held: []string{"ccccccccccccccccccccccccc\n"}, shown: []string{"c\n"}},
+			},
+			fails: []string{"a\n", "b\n", "c\n"},
+		}, {
+			what: "already trimmed",
+			max:  40,
+			failures: []failure{
+				{held: []string{droppedMarker, "a\n"}, shown: []string{droppedMarker, "a\n"}},
+				{held: []string{droppedMarker, "b\n"}, shown: []string{droppedMarker, "b\n"}},
+			},
+			fails: []string{droppedMarker, "b\n"},
+		},
+	}
+	for _, tt := range tests {
+		b := outputBudget{max: tt.max}
+		var fails []string
+		for _, f := range tt.failures {
+			for _, line := range f.held {
+				b.hold(line)
+			}
+			fails = b.keep(fails, f.held, f.shown)
+			if held := len(strings.Join(fails, "")); b.used != held {
+				t.Errorf("%s: budget says %d bytes are held, but %d are", tt.what, b.used, held)
+			}
+		}
+		if !reflect.DeepEqual(fails, tt.fails) {
+			t.Errorf("%s: expected %q, got %q", tt.what, tt.fails, fails)
+		}
+	}
+}
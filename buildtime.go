@@ -0,0 +1,83 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// buildTimes splits how long each package took into building (well,
+// everything before its tests started, so waiting for its turn as
+// well) and running its tests. A nil buildTimes does nothing.
+type buildTimes struct {
+	began   time.Time
+	started map[string]time.Time
+	took    map[string]float64
+	names   map[string]string
+	pkgs    []string
+}
+
+func newBuildTimes() *buildTimes {
+	return &buildTimes{
+		started: map[string]time.Time{},
+		took:    map[string]float64{},
+		names:   map[string]string{},
+	}
+}
+
+// begin marks the start of the run.
+func (b *buildTimes) begin() {
+	if b == nil {
+		return
+	}
+	b.began = time.Now()
+}
+
+func (b *buildTimes) add(ev *TestEvent) {
+	if b == nil || ev.Package == "" || ev.Time.IsZero() {
+		return
+	}
+	if ev.Time.Before(b.began) {
+		// replaying an old run, most likely
+		b.began = ev.Time
+	}
+	if _, ok := b.started[ev.Package]; !ok && ev.Action != "output" {
+		b.started[ev.Package] = ev.Time
+	}
+	if ev.Test == "" && ev.isResult() {
+		if _, ok := b.took[ev.Package]; !ok {
+			b.pkgs = append(b.pkgs, ev.Package)
+		}
+		b.took[ev.Package] = ev.Elapsed
+		b.names[ev.Package] = ev.pkg()
+	}
+}
+
+// report shows the split for the slowest few packages.
+func (b *buildTimes) report(w io.Writer) {
+	if b == nil || len(b.pkgs) == 0 {
+		return
+	}
+	build := func(pkg string) float64 {
+		return b.started[pkg].Sub(b.began).Seconds()
+	}
+	sort.SliceStable(b.pkgs, func(i, j int) bool {
+		return build(b.pkgs[i])+b.took[b.pkgs[i]] > build(b.pkgs[j])+b.took[b.pkgs[j]]
+	})
+	pkgs := b.pkgs
+	if len(pkgs) > 5 {
+		pkgs = pkgs[:5]
+	}
+	fmt.Fprintln(w, "\nSlowest packages:")
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	for _, pkg := range pkgs {
+		fmt.Fprintf(tw, "  %s\tbuild: %.1fs,\trun: %.1fs\n", b.names[pkg], build(pkg), b.took[pkg])
+	}
+	tw.Flush()
+}
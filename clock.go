@@ -0,0 +1,118 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// a clock ticks away in the corner so you know things are still
+// happening even when go test is being quiet (e.g. building with -race).
+//
+// Anything that wants to write to stdout while the clock is running
+// needs to Lock it first (which also wipes the clock off the line),
+// and Unlock it when done. A nil clock is fine to Lock and Unlock.
+//
+// An animated clock instead only shows up when nothing's been written
+// for a second or so, with a spinner next to it.
+type clock struct {
+	mu      sync.Mutex
+	esc     *escape
+	start   time.Time
+	drawn   bool
+	done    chan struct{}
+	once    sync.Once
+	animate bool
+	last    time.Time
+	frame   int
+}
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+func startClock(ctx context.Context, esc *escape, animate bool) *clock {
+	now := time.Now()
+	c := &clock{
+		esc:     esc,
+		start:   now,
+		last:    now,
+		done:    make(chan struct{}),
+		animate: animate,
+	}
+	go c.tick(ctx)
+	return c
+}
+
+func (c *clock) tick(ctx context.Context) {
+	every := time.Second
+	if c.animate {
+		every = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.Lock()
+			c.Unlock()
+			return
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			c.draw(now)
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (c *clock) draw(now time.Time) {
+	text := " " + now.Sub(c.start).Round(time.Second).String()
+	if c.animate {
+		if now.Sub(c.last) < time.Second {
+			return
+		}
+		text = " " + spinner[c.frame%len(spinner)] + text
+		c.frame++
+	}
+	c.wipe()
+	fmt.Print(c.esc.stash(text))
+	c.drawn = true
+}
+
+func (c *clock) wipe() {
+	if c.drawn {
+		fmt.Print(c.esc.unstash)
+		c.drawn = false
+	}
+}
+
+func (c *clock) Lock() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.wipe()
+}
+
+func (c *clock) Unlock() {
+	if c == nil {
+		return
+	}
+	c.last = time.Now()
+	c.mu.Unlock()
+}
+
+// stop the clock, and get it out of the way.
+func (c *clock) stop() {
+	if c == nil {
+		return
+	}
+	c.once.Do(func() { close(c.done) })
+	c.Lock()
+	c.Unlock()
+}
@@ -5,10 +5,13 @@ package main
 // from https://github.com/chipaca/goctest
 
 import (
+	"bufio"
+	"errors"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -47,6 +50,25 @@ func TestCommon(t *testing.T) {
 	}
 }
 
+// check that long lines don't trip up the scanner
+func TestLongLines(t *testing.T) {
+	long := strings.Repeat("x", 3*bufio.MaxScanTokenSize)
+	scanner := newScanner(strings.NewReader("short\n" + long + "\nshort\n"))
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scanner failed: %v", err)
+	}
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	if lines[1] != long {
+		t.Errorf("long line came back %d bytes long, expected %d", len(lines[1]), len(long))
+	}
+}
+
 // check tht the output of ‘goctest -h’ mentioned in the README
 // matches the usage string
 func TestREADME(t *testing.T) {
@@ -73,3 +95,65 @@ func TestREADME(t *testing.T) {
 		cmd.Run()
 	}
 }
+
+// an expected failure, or a flaky test, doesn't excuse anything else
+func TestExitStatus(t *testing.T) {
+	b := &baseline{
+		names:      map[string]bool{"a:TestKnown": true},
+		xfailed:    map[string]bool{},
+		expected:   map[string]bool{},
+		unexpected: map[string]int{},
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh to make go test's exit status with")
+	}
+	exit := func(n int) error { return exec.Command(sh, "-c", "exit "+strconv.Itoa(n)).Run() }
+
+	known := []TestEvent{
+		{Action: "fail", Package: "a", Test: "TestKnown"},
+		{Action: "fail", Package: "a"},
+	}
+	flaky := []TestEvent{
+		{Action: "pass", Package: "f", Test: "TestFlaky"},
+		{Action: "fail", Package: "f", Test: "TestFlaky"},
+		{Action: "fail", Package: "f"},
+	}
+	tests := []struct {
+		what   string
+		events []TestEvent
+		goErr  error
+		status int
+	}{
+		{"only an expected failure", known, exit(1), 0},
+		{"only a flaky test", flaky, exit(1), 0},
+		{"an expected failure and a failed test", append(known[:1:1],
+			TestEvent{Action: "fail", Package: "b", Test: "TestB"},
+			TestEvent{Action: "fail", Package: "b"},
+			known[1]), exit(1), 1},
+		{"an expected failure and a package failing outside of its tests", append(known[:1:1],
+			TestEvent{Action: "pass", Package: "b", Test: "TestB"},
+			TestEvent{Action: "fail", Package: "b"},
+			known[1]), exit(1), 1},
+		{"a flaky test and a build failure", append(flaky[:3:3],
+			TestEvent{Action: "error", Package: "b", Test: errorPlaceholder}), exit(1), 1},
+		{"an expected failure and go test failing otherwise", known, exit(2), 2},
+		{"an expected failure and go test not running", known, errors.New("nope"), 1},
+	}
+	for _, tt := range tests {
+		b.xfailed = map[string]bool{}
+		b.expected = map[string]bool{}
+		b.unexpected = map[string]int{}
+		var ss summary
+		f := newFlakes()
+		for _, ev := range tt.events {
+			ev := ev
+			b.adjust(&ev)
+			f.check(&ev, &ss)
+			ss.add(&ev)
+		}
+		if status := exitStatus(&ss, tt.goErr); status != tt.status {
+			t.Errorf("with %s, expected exit status %d, got %d", tt.what, tt.status, status)
+		}
+	}
+}
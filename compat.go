@@ -0,0 +1,95 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// what go test says instead of running a package it couldn't build
+var brokenRx = regexp.MustCompile(`^FAIL\s+(\S+)\s+\[(?:build|setup) failed\]`)
+
+// compat irons out the ways ‘go test -json’ has said the same thing
+// differently over the years, so the rest of goctest only has to deal
+// with the one:
+//
+//   - up to go1.23 build failures come as non-JSON lines (handled where
+//     the lines are read, as they aren't events);
+//   - go1.20 added "start", and since then failures to set a package up
+//     (and, depending on the version, to build it) come as a JSON
+//     "FAIL pkg [setup failed]" output, followed by the package failing;
+//   - go1.24 added "build-output" and "build-fail", and the package
+//     failing after that carries a FailedBuild; the build events'
+//     ImportPath is what failed to build, which needn't be the package
+//     (e.g. it's the missing dependency when setting up fails), so it's
+//     only by that FailedBuild that the build is put down to a package;
+//   - the package results of some versions leave out Elapsed.
+//
+// Which of these a stream has is told by the stream itself rather than
+// by asking ‘go version’, as what's being read needn't have come from
+// the go that's in the PATH.
+type compat struct {
+	// started is when each package was first heard from
+	started map[string]time.Time
+	// builds has what each build said, by import path, for when a
+	// package's failing says it was down to it
+	builds map[string][]string
+	// broken has the "FAIL pkg [setup failed]" lines of packages not yet
+	// heard failing
+	broken map[string]string
+}
+
+func newCompat() *compat {
+	return &compat{
+		started: make(map[string]time.Time),
+		builds:  make(map[string][]string),
+		broken:  make(map[string]string),
+	}
+}
+
+// normalize adjusts the event to look like the current ones; it returns
+// false if the event says nothing that isn't said (or to be said) by
+// another.
+func (c *compat) normalize(ev *TestEvent) bool {
+	switch ev.Action {
+	case "build-output":
+		c.builds[ev.ImportPath] = append(c.builds[ev.ImportPath], ev.Output)
+		return false
+	case "build-fail":
+		// the package failing will say it was down to this build
+		return false
+	}
+	if ev.Package == "" || ev.Test != "" {
+		return true
+	}
+	if _, ok := c.started[ev.Package]; !ok {
+		c.started[ev.Package] = ev.Time
+	}
+	switch {
+	case ev.Action == "output" && brokenRx.MatchString(ev.Output):
+		// held until the package fails, which might say why
+		c.broken[ev.Package] = ev.Output
+		return false
+	case ev.Action == "fail" && ev.FailedBuild != "":
+		// what the build said says it better than the FAIL line
+		delete(c.broken, ev.Package)
+		ev.Action = "error"
+		ev.Test = errorPlaceholder
+		ev.Output = strings.Join(c.builds[ev.FailedBuild], "")
+	case ev.isResult() && c.broken[ev.Package] != "":
+		// what older go says outside of the JSON
+		ev.Action = "error"
+		ev.Test = errorPlaceholder
+		ev.Output = c.broken[ev.Package]
+		delete(c.broken, ev.Package)
+	case ev.isResult() && ev.Elapsed == 0:
+		if start := c.started[ev.Package]; !start.IsZero() && ev.Time.After(start) {
+			ev.Elapsed = ev.Time.Sub(start).Seconds()
+		}
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// what each version of go test -json says for a package that passes, a
+// package without tests, and a package that doesn't build
+var compatSamples = map[string]string{
+	"go1.19": `
+{"Time":"2021-01-01T00:00:00Z","Action":"run","Package":"example.com/a","Test":"TestA"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/a","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Time":"2021-01-01T00:00:01Z","Action":"pass","Package":"example.com/a","Test":"TestA","Elapsed":1}
+{"Time":"2021-01-01T00:00:01Z","Action":"output","Package":"example.com/a","Output":"ok  \texample.com/a\t1.002s\n"}
+{"Time":"2021-01-01T00:00:01Z","Action":"pass","Package":"example.com/a","Elapsed":1.002}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/b","Output":"?   \texample.com/b\t[no test files]\n"}
+{"Time":"2021-01-01T00:00:00Z","Action":"skip","Package":"example.com/b"}
+`,
+	"go1.21": `
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/a"}
+{"Time":"2021-01-01T00:00:00Z","Action":"run","Package":"example.com/a","Test":"TestA"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/a","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Time":"2021-01-01T00:00:01Z","Action":"pass","Package":"example.com/a","Test":"TestA","Elapsed":1}
+{"Time":"2021-01-01T00:00:01Z","Action":"output","Package":"example.com/a","Output":"ok  \texample.com/a\t1.002s\n"}
+{"Time":"2021-01-01T00:00:01Z","Action":"pass","Package":"example.com/a","Elapsed":1.002}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/b"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/b","Output":"?   \texample.com/b\t[no test files]\n"}
+{"Time":"2021-01-01T00:00:00Z","Action":"skip","Package":"example.com/b","Elapsed":0}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/c"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/c","Output":"FAIL\texample.com/c [setup failed]\n"}
+{"Time":"2021-01-01T00:00:00Z","Action":"fail","Package":"example.com/c","Elapsed":0}
+`,
+	"go1.24": `
+{"ImportPath":"example.com/c [example.com/c.test]","Action":"build-output","Output":"# example.com/c\n"}
+{"ImportPath":"example.com/c [example.com/c.test]","Action":"build-output","Output":"c/c_test.go:1:1: oops\n"}
+{"ImportPath":"example.com/c [example.com/c.test]","Action":"build-fail"}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/a"}
+{"Time":"2021-01-01T00:00:00Z","Action":"run","Package":"example.com/a","Test":"TestA"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/a","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Time":"2021-01-01T00:00:01Z","Action":"pass","Package":"example.com/a","Test":"TestA","Elapsed":1}
+{"Time":"2021-01-01T00:00:01Z","Action":"output","Package":"example.com/a","Output":"ok  \texample.com/a\t1.002s\n"}
+{"Time":"2021-01-01T00:00:01Z","Action":"pass","Package":"example.com/a","Elapsed":1.002}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/b"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/b","Output":"?   \texample.com/b\t[no test files]\n"}
+{"Time":"2021-01-01T00:00:00Z","Action":"skip","Package":"example.com/b","Elapsed":0}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/c"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/c","Output":"FAIL\texample.com/c [build failed]\n"}
+{"Time":"2021-01-01T00:00:00Z","Action":"fail","Package":"example.com/c","Elapsed":0,"FailedBuild":"example.com/c [example.com/c.test]"}
+`,
+}
+
+func TestCompat(t *testing.T) {
+	for version, sample := range compatSamples {
+		c := newCompat()
+		var ss summary
+		for _, line := range strings.Split(strings.TrimSpace(sample), "\n") {
+			var ev TestEvent
+			if err := json.Unmarshal([]byte(line), &ev); err != nil {
+				t.Fatalf("%s: %v", version, err)
+			}
+			if c.normalize(&ev) {
+				ss.add(&ev)
+			}
+		}
+		if ss.tests.total != 1 || ss.tests.passed != 1 {
+			t.Errorf("%s: expected the one test to pass, got %+v", version, ss.tests)
+		}
+		if ss.packages.passed != 1 || ss.packages.skipped != 1 || ss.packages.failed != 0 {
+			t.Errorf("%s: expected a package to pass and one to be skipped, got %+v", version, ss.packages)
+		}
+		errored := 1
+		if version == "go1.19" {
+			// the build failure is a non-JSON line
+			errored = 0
+		}
+		if ss.packages.errored != errored {
+			t.Errorf("%s: expected %d package(s) to error, got %d", version, errored, ss.packages.errored)
+		}
+	}
+}
+
+// a package result with no Elapsed takes it from when the package started
+func TestCompatElapsed(t *testing.T) {
+	c := newCompat()
+	var ev TestEvent
+	for _, line := range []string{
+		`{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/a","Output":"ok\n"}`,
+		`{"Time":"2021-01-01T00:00:02.5Z","Action":"pass","Package":"example.com/a"}`,
+	} {
+		ev = TestEvent{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		c.normalize(&ev)
+	}
+	if ev.Elapsed != 2.5 {
+		t.Errorf("expected an elapsed of 2.5s, got %v", ev.Elapsed)
+	}
+}
+
+// on go1.24 a package that can't be set up (here, for a missing
+// dependency) has build events whose ImportPath is the dependency; it's
+// the package that errors, once, with what the build said
+func TestCompatSetupFailed(t *testing.T) {
+	c := newCompat()
+	var ss summary
+	var errs []TestEvent
+	for _, line := range strings.Split(strings.TrimSpace(`
+{"ImportPath":"nope.com/x","Action":"build-output","Output":"# example.com/m2\n"}
+{"ImportPath":"nope.com/x","Action":"build-output","Output":"m_test.go:6:2: no required module provides package nope.com/x\n"}
+{"ImportPath":"nope.com/x","Action":"build-fail"}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/m2"}
+{"Time":"2021-01-01T00:00:00Z","Action":"output","Package":"example.com/m2","Output":"FAIL\texample.com/m2 [setup failed]\n","OutputType":"frame"}
+{"Time":"2021-01-01T00:00:00Z","Action":"fail","Package":"example.com/m2","Elapsed":0,"FailedBuild":"nope.com/x"}
+{"Time":"2021-01-01T00:00:00Z","Action":"start","Package":"example.com/m2/ok"}
+{"Time":"2021-01-01T00:00:00Z","Action":"run","Package":"example.com/m2/ok","Test":"TestOK"}
+{"Time":"2021-01-01T00:00:00Z","Action":"pass","Package":"example.com/m2/ok","Test":"TestOK","Elapsed":0}
+{"Time":"2021-01-01T00:00:00Z","Action":"pass","Package":"example.com/m2/ok","Elapsed":0.003}
+`), "\n") {
+		var ev TestEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if !c.normalize(&ev) {
+			continue
+		}
+		ss.add(&ev)
+		if ev.Action == "error" {
+			errs = append(errs, ev)
+		}
+	}
+	if len(errs) != 1 || ss.packages.errored != 1 {
+		t.Fatalf("expected one package to error, got %d (%+v)", ss.packages.errored, errs)
+	}
+	if errs[0].Package != "example.com/m2" {
+		t.Errorf("expected example.com/m2 to error, not %q", errs[0].Package)
+	}
+	expected := "# example.com/m2\nm_test.go:6:2: no required module provides package nope.com/x\n"
+	if errs[0].Output != expected {
+		t.Errorf("expected the error to be what the build said, %q, got %q", expected, errs[0].Output)
+	}
+	if ss.packages.passed != 1 || ss.packages.failed != 0 {
+		t.Errorf("expected the other package to pass, got %+v", ss.packages)
+	}
+}
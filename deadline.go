@@ -0,0 +1,50 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// pkgDeadline spots packages whose tests took longer than they should.
+// It doesn't stop them; that's what go test's -timeout is for. A nil
+// pkgDeadline does nothing.
+type pkgDeadline struct {
+	limit time.Duration
+	took  map[string]float64
+	names map[string]string
+	pkgs  []string
+}
+
+func newPkgDeadline(limit time.Duration) *pkgDeadline {
+	return &pkgDeadline{
+		limit: limit,
+		took:  map[string]float64{},
+		names: map[string]string{},
+	}
+}
+
+func (d *pkgDeadline) add(ev *TestEvent) {
+	if d == nil || ev.Test != "" || !ev.isResult() || ev.cached {
+		return
+	}
+	// a package can be tested more than once, e.g. in a workspace
+	d.took[ev.Package] += ev.Elapsed
+	if _, ok := d.names[ev.Package]; !ok && d.took[ev.Package] > d.limit.Seconds() {
+		d.names[ev.Package] = ev.pkg()
+		d.pkgs = append(d.pkgs, ev.Package)
+	}
+}
+
+func (d *pkgDeadline) report(w io.Writer, esc *escape) {
+	if d == nil {
+		return
+	}
+	for _, pkg := range d.pkgs {
+		fmt.Fprintf(w, "%sPackage %s exceeded %s%s (took %s).\n", esc.fail, d.names[pkg], d.limit, esc.endc, secs(d.took[pkg]))
+	}
+}
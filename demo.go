@@ -0,0 +1,115 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"time"
+)
+
+// demoPrefix is what the made-up packages of the demo are under.
+const demoPrefix = "example.com/demo"
+
+// demoEvents makes up a run of go test with a bit of everything in it:
+// tests that pass, fail, skip, and run in parallel, subtests, a
+// benchmark, a panic, a package without tests, a cached package, and a
+// package that doesn't build.
+func demoEvents() []TestEvent {
+	t0 := time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC)
+	var evs []TestEvent
+	at := 0.0
+	add := func(secs float64, ev TestEvent) {
+		at += secs
+		ev.Time = t0.Add(time.Duration(at * float64(time.Second)))
+		if ev.Package != "" {
+			ev.Package = demoPrefix + "/" + ev.Package
+		}
+		evs = append(evs, ev)
+	}
+	run := func(pkg, test string) {
+		add(0, TestEvent{Action: "run", Package: pkg, Test: test})
+		add(0, TestEvent{Action: "output", Package: pkg, Test: test, Output: "=== RUN   " + test + "\n"})
+	}
+	result := func(secs float64, action, pkg, test string, output ...string) {
+		for _, line := range output {
+			add(0, TestEvent{Action: "output", Package: pkg, Test: test, Output: line})
+		}
+		verb := map[string]string{"pass": "PASS", "fail": "FAIL", "skip": "SKIP"}[action]
+		add(secs, TestEvent{Action: "output", Package: pkg, Test: test,
+			Output: fmt.Sprintf("--- %s: %s (%.2fs)\n", verb, test, secs)})
+		add(0, TestEvent{Action: action, Package: pkg, Test: test, Elapsed: secs})
+	}
+	// the package took secs, all told
+	done := func(secs float64, action, pkg, output string) {
+		add(0, TestEvent{Action: "output", Package: pkg, Output: output})
+		add(0, TestEvent{Action: action, Package: pkg, Elapsed: secs})
+	}
+
+	// a package that doesn't build, as newer go says it
+	evs = append(evs,
+		TestEvent{Action: "build-output", ImportPath: demoPrefix + "/broken [" + demoPrefix + "/broken.test]", Output: "# " + demoPrefix + "/broken\n"},
+		TestEvent{Action: "build-output", ImportPath: demoPrefix + "/broken [" + demoPrefix + "/broken.test]", Output: "broken/broken.go:3:2: undefined: frobnicate\n"},
+		TestEvent{Action: "build-fail", ImportPath: demoPrefix + "/broken [" + demoPrefix + "/broken.test]"},
+	)
+	add(0, TestEvent{Action: "start", Package: "broken"})
+	add(0, TestEvent{Action: "output", Package: "broken", Output: "FAIL\t" + demoPrefix + "/broken [build failed]\n"})
+	add(0, TestEvent{Action: "fail", Package: "broken", FailedBuild: demoPrefix + "/broken [" + demoPrefix + "/broken.test]"})
+
+	add(0, TestEvent{Action: "start", Package: "cached"})
+	run("cached", "TestCached")
+	result(0, "pass", "cached", "TestCached")
+	done(0, "pass", "cached", "ok  \t"+demoPrefix+"/cached\t(cached)\n")
+
+	add(0, TestEvent{Action: "start", Package: "empty"})
+	done(0, "skip", "empty", "?   \t"+demoPrefix+"/empty\t[no test files]\n")
+
+	add(0, TestEvent{Action: "start", Package: "happy"})
+	run("happy", "TestAdd")
+	result(0.01, "pass", "happy", "TestAdd")
+	run("happy", "TestParallel")
+	add(0, TestEvent{Action: "pause", Package: "happy", Test: "TestParallel"})
+	add(0, TestEvent{Action: "output", Package: "happy", Test: "TestParallel", Output: "=== PAUSE TestParallel\n"})
+	run("happy", "TestTable")
+	for _, sub := range []string{"TestTable/empty", "TestTable/one", "TestTable/many"} {
+		run("happy", sub)
+		result(0.02, "pass", "happy", sub)
+	}
+	result(0.06, "pass", "happy", "TestTable")
+	add(0.1, TestEvent{Action: "cont", Package: "happy", Test: "TestParallel"})
+	add(0, TestEvent{Action: "output", Package: "happy", Test: "TestParallel", Output: "=== CONT  TestParallel\n"})
+	result(0.5, "pass", "happy", "TestParallel")
+	run("happy", "TestNetwork")
+	result(0, "skip", "happy", "TestNetwork", "    happy_test.go:42: needs network\n")
+	add(0, TestEvent{Action: "output", Package: "happy", Output: "goos: linux\n"})
+	add(0, TestEvent{Action: "output", Package: "happy", Output: "BenchmarkAdd\n"})
+	add(1, TestEvent{Action: "bench", Package: "happy", Test: "BenchmarkAdd", Output: "BenchmarkAdd-8   \t1000000000\t         0.25 ns/op\n"})
+	done(1.812, "pass", "happy", "ok  \t"+demoPrefix+"/happy\t1.812s\n")
+
+	add(0, TestEvent{Action: "start", Package: "sad"})
+	run("sad", "TestSum")
+	result(0.01, "fail", "sad", "TestSum",
+		"    sad_test.go:17: Sum([1 2 3]) is wrong:\n",
+		"        got: 5\n",
+		"        want: 6\n")
+	run("sad", "TestPanics")
+	add(0.2, TestEvent{Action: "output", Package: "sad", Test: "TestPanics", Output: "--- FAIL: TestPanics (0.20s)\n"})
+	for _, line := range []string{
+		"panic: runtime error: index out of range [3] with length 3 [recovered]\n",
+		"\n",
+		"goroutine 7 [running]:\n",
+		"testing.tRunner.func1.2({0x5a4b20, 0xc000018258})\n",
+		"\t/usr/lib/go/src/testing/testing.go:1545 +0x238\n",
+		demoPrefix + "/sad.TestPanics(0xc0000076c0)\n",
+		"\t/home/demo/sad/sad_test.go:25 +0x1d\n",
+		"created by testing.(*T).Run in goroutine 1\n",
+		"\t/usr/lib/go/src/testing/testing.go:1648 +0x3ad\n",
+	} {
+		add(0, TestEvent{Action: "output", Package: "sad", Test: "TestPanics", Output: line})
+	}
+	add(0, TestEvent{Action: "fail", Package: "sad", Test: "TestPanics", Elapsed: 0.2})
+	done(0.214, "fail", "sad", "FAIL\t"+demoPrefix+"/sad\t0.214s\n")
+
+	return evs
+}
@@ -0,0 +1,47 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// what the test got, i.e. the bit that's wrong
+	gotRx = regexp.MustCompile(`(?i)\b(?:got|actual|have)\s*[:=]`)
+	// what the test wanted
+	wantRx = regexp.MustCompile(`(?i)\b(?:want|wanted|expected)\s*[:=]`)
+	// a line from a unified diff (but not a ‘--- FAIL’)
+	diffRx = regexp.MustCompile(`^\s*([-+])\s`)
+)
+
+// highlightDiffs colours the lines of a failed test's output that look
+// like what it got versus what it wanted, or like a diff between the
+// two. Everything else is left alone.
+func highlightDiffs(output []string, esc *escape) []string {
+	highlighted := make([]string, len(output))
+	for i, line := range output {
+		var colour string
+		if m := diffRx.FindStringSubmatch(line); m != nil {
+			if m[1] == "-" {
+				colour = esc.fail
+			} else {
+				colour = esc.pass
+			}
+		} else if gotRx.MatchString(line) {
+			colour = esc.fail
+		} else if wantRx.MatchString(line) {
+			colour = esc.pass
+		}
+		if colour == "" {
+			highlighted[i] = line
+			continue
+		}
+		text := strings.TrimRight(line, "\n")
+		highlighted[i] = colour + text + esc.endc + line[len(text):]
+	}
+	return highlighted
+}
@@ -0,0 +1,57 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// envVars are the bits of ‘go env’ that most affect how tests run.
+var envVars = []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED", "GOEXPERIMENT"}
+
+// envInfo says what a run was done with, so that results can be
+// reproduced (or at least argued about).
+type envInfo struct {
+	goVersion string
+	goEnv     []string
+	args      []string
+}
+
+func gatherEnvInfo(ctx context.Context) *envInfo {
+	info := &envInfo{args: os.Args}
+	out, err := exec.CommandContext(ctx, "go", "version").Output()
+	if err != nil {
+		info.goVersion = fmt.Sprintf("(unknown: %v)", err)
+	} else {
+		info.goVersion = strings.TrimSpace(string(out))
+	}
+	out, err = exec.CommandContext(ctx, "go", append([]string{"env"}, envVars...)...).Output()
+	if err == nil {
+		values := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+		for i, name := range envVars {
+			if i < len(values) && values[i] != "" {
+				info.goEnv = append(info.goEnv, name+"="+values[i])
+			}
+		}
+	}
+	return info
+}
+
+func (info *envInfo) report(w io.Writer) {
+	if info == nil {
+		return
+	}
+	fmt.Fprintln(w, "\nThis was:")
+	fmt.Fprintln(w, " ", info.goVersion)
+	if len(info.goEnv) > 0 {
+		fmt.Fprintln(w, " ", strings.Join(info.goEnv, " "))
+	}
+	fmt.Fprintln(w, " ", shellQuote(info.args))
+}
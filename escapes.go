@@ -6,10 +6,27 @@ import (
 )
 
 type escape struct {
+	name                               string
 	fail, pass, skip, zero, nope, endc string
 	rgb                                func(rgb [3]uint8) string
 	uri                                func(url, text string) string
 	em                                 func(text string) string
+	// mark makes text stand out, differently for different n
+	mark func(n int, text string) string
+	// stash writes text after the cursor without moving it, and
+	// unstash gets rid of whatever was stashed.
+	stash   func(text string) string
+	unstash string
+	// clear clears the screen
+	clear string
+}
+
+// marks are the background colours ‘full’ marks things with, in turn.
+var marks = [][3]uint8{
+	{95, 95, 0},
+	{0, 95, 135},
+	{95, 0, 95},
+	{0, 95, 95},
 }
 
 const (
@@ -21,6 +38,7 @@ const (
 
 var escapes = []*escape{
 	{
+		name: "full",
 		fail: "\033[38;5;124m",
 		pass: "\033[38;5;034m",
 		skip: "\033[38;5;244m",
@@ -36,7 +54,17 @@ var escapes = []*escape{
 		em: func(text string) string {
 			return fmt.Sprintf("\033[3m%s\033[23m", text)
 		},
+		mark: func(n int, text string) string {
+			bg := marks[n%len(marks)]
+			return fmt.Sprintf("\033[48;2;%d;%d;%dm%s\033[49m", bg[0], bg[1], bg[2], text)
+		},
+		stash: func(text string) string {
+			return "\0337\033[38;5;244m" + text + "\033[0m\0338"
+		},
+		unstash: "\033[K",
+		clear:   "\033[H\033[2J",
 	}, {
+		name: "mono",
 		fail: "\033[7m", // reversed
 		pass: "\033[1m", // bold
 		skip: "\033[2m", // dim
@@ -50,12 +78,25 @@ var escapes = []*escape{
 		em: func(text string) string {
 			return fmt.Sprintf("\033[3m%s\033[23m", text)
 		},
+		mark: func(n int, text string) string {
+			return fmt.Sprintf("\033[7m%s\033[27m", text)
+		},
+		stash: func(text string) string {
+			return "\0337\033[2m" + text + "\033[0m\0338"
+		},
+		unstash: "\033[K",
+		clear:   "\033[H\033[2J",
 	}, {
+		name: "bare",
 		fail: "", pass: "", skip: "", zero: "", nope: "", endc: "",
-		rgb: func(rgb [3]uint8) string { return "" },
-		uri: func(url string, text string) string { return text },
-		em:  func(text string) string { return "*" + text + "*" },
+		rgb:     func(rgb [3]uint8) string { return "" },
+		uri:     func(url string, text string) string { return text },
+		em:      func(text string) string { return "*" + text + "*" },
+		mark:    func(n int, text string) string { return "*" + text + "*" },
+		stash:   func(text string) string { return "" },
+		unstash: "",
 	}, {
+		name: "test",
 		fail: "FAIL",
 		pass: "PASS",
 		skip: "SKIP",
@@ -63,7 +104,7 @@ var escapes = []*escape{
 		nope: "NOPE",
 		endc: "ENDC",
 		rgb: func(rgb [3]uint8) string {
-			return fmt.Sprintf("#%2x%2x%2x", rgb[0], rgb[1], rgb[2])
+			return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
 		},
 		uri: func(url string, text string) string {
 			return fmt.Sprintf("[%s](%s)", text, url)
@@ -71,6 +112,11 @@ var escapes = []*escape{
 		em: func(text string) string {
 			return "*" + text + "*"
 		},
+		mark: func(n int, text string) string {
+			return "*" + text + "*"
+		},
+		stash:   func(text string) string { return "" },
+		unstash: "",
 	},
 }
 
@@ -0,0 +1,65 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"testing"
+)
+
+// check the test escapes render colourForRatio's colours as proper hex
+func TestTestEscRGB(t *testing.T) {
+	tests := []struct {
+		p, q int
+		hex  string
+	}{
+		{0, 10, "#af0000"},
+		{1, 9, "#ae2f00"},
+		{2, 9, "#a94800"},
+		{3, 9, "#a05e00"},
+		{4, 9, "#937100"},
+		{5, 9, "#828200"},
+		{6, 9, "#6d9200"},
+		{7, 9, "#4ea100"},
+		{8, 9, "#00af00"},
+		{9, 9, "#00af00"},
+		{-1, 9, "#af0000"},
+	}
+	rgb := escapes[testEsc].rgb
+	for _, tt := range tests {
+		if hex := rgb(colourForRatio(tt.p, tt.q)); hex != tt.hex {
+			t.Errorf("colour for %d/%d is %q, expected %q", tt.p, tt.q, hex, tt.hex)
+		}
+	}
+}
+
+// with --green-at and --red-at the colours are spread over what's between
+func TestSummaryColour(t *testing.T) {
+	ss := summary{greenAt: 95, redAt: 50}
+	tests := []struct {
+		p, q int
+		hex  string
+	}{
+		{100, 100, "#00af00"},
+		{95, 100, "#00af00"},
+		{94, 100, "#4ea100"},
+		{73, 100, "#937100"},
+		{60, 100, "#ae2f00"},
+		{50, 100, "#af0000"},
+		{10, 100, "#af0000"},
+	}
+	rgb := escapes[testEsc].rgb
+	for _, tt := range tests {
+		if hex := rgb(ss.colour(tt.p, tt.q)); hex != tt.hex {
+			t.Errorf("colour for %d/%d is %q, expected %q", tt.p, tt.q, hex, tt.hex)
+		}
+	}
+	// unset, it's as before
+	ss = defaultOptions().summary()
+	for p := 0; p <= 9; p++ {
+		if hex, expected := rgb(ss.colour(p, 9)), rgb(colourForRatio(p, 9)); hex != expected {
+			t.Errorf("colour for %d/9 is %q, expected %q", p, hex, expected)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// how long a filter command gets to deal with a test's output.
+const filterTimeout = 10 * time.Second
+
+// an outputFilter preprocesses the output of failed tests before it's
+// shown, either with an inline regexp substitution or by piping it
+// through a command. A nil outputFilter leaves the output alone.
+type outputFilter struct {
+	rx   *regexp.Regexp
+	repl string
+	cmd  string
+}
+
+// newFilter takes either a substitution in the form ‘s/regexp/replacement/’
+// (with any delimiter in place of the slash), or a command for the shell.
+func newFilter(spec string) (*outputFilter, error) {
+	if len(spec) > 1 && spec[0] == 's' {
+		delim := spec[1:2]
+		parts := strings.Split(spec[2:], delim)
+		if len(parts) == 3 && parts[2] == "" {
+			rx, err := regexp.Compile(parts[0])
+			if err != nil {
+				return nil, fmt.Errorf("bad filter regexp: %v", err)
+			}
+			return &outputFilter{rx: rx, repl: parts[1]}, nil
+		}
+	}
+	return &outputFilter{cmd: spec}, nil
+}
+
+// apply the filter to the lines of output. If the command fails, the
+// output is returned unfiltered.
+func (f *outputFilter) apply(ctx context.Context, output []string) []string {
+	if f == nil || len(output) == 0 {
+		return output
+	}
+	if f.rx != nil {
+		filtered := make([]string, len(output))
+		for i, line := range output {
+			filtered[i] = f.rx.ReplaceAllString(line, f.repl)
+		}
+		return filtered
+	}
+	ctx, cancel := context.WithTimeout(ctx, filterTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", f.cmd)
+	cmd.Stdin = strings.NewReader(strings.Join(output, ""))
+	out, err := cmd.Output()
+	if err != nil {
+		log.Printf("Filter %q failed (%v); showing the output as is.", f.cmd, err)
+		return output
+	}
+	var filtered []string
+	for len(out) > 0 {
+		idx := bytes.IndexByte(out, '\n')
+		if idx < 0 {
+			filtered = append(filtered, string(out)+"\n")
+			break
+		}
+		filtered = append(filtered, string(out[:idx+1]))
+		out = out[idx+1:]
+	}
+	return filtered
+}
@@ -0,0 +1,88 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// flakes keeps track of the outcome of each test, to spot the ones that
+// both pass and fail within the same run (e.g. with -count).
+type flakes struct {
+	last  map[string]string
+	names []string
+	// full are the names of the flaky tests, with untrimmed packages
+	full []string
+}
+
+func newFlakes() *flakes {
+	return &flakes{last: map[string]string{}}
+}
+
+// check looks at a test's outcome and, if it contradicts an earlier one,
+// takes the earlier one out of the sums and marks the test as flaky.
+func (f *flakes) check(ev *TestEvent, ss *summary) {
+	if f == nil || !ev.isTest() || ss.isParent(ev) {
+		return
+	}
+	if ev.Action != "pass" && ev.Action != "fail" {
+		return
+	}
+	name := ev.name()
+	prev, seen := f.last[name]
+	if !seen {
+		f.last[name] = ev.Action
+		return
+	}
+	if prev == ev.Action {
+		return
+	}
+	if prev != "flaky" {
+		f.names = append(f.names, name)
+		f.full = append(f.full, ev.Package+":"+ev.Test)
+	}
+	ss.tests.remove(prev)
+	ev.Action = "flaky"
+	f.last[name] = ev.Action
+}
+
+// quarantine adds the flaky tests to the given file, in a form fit for
+// use as a baseline, leaving out any that are already in it.
+func (f *flakes) quarantine(path string) error {
+	if f == nil || len(f.full) == 0 {
+		return nil
+	}
+	known := map[string]bool{}
+	if old, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(old)
+		for scanner.Scan() {
+			known[strings.TrimSpace(scanner.Text())] = true
+		}
+		old.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	for _, name := range f.full {
+		if known[name] {
+			continue
+		}
+		known[name] = true
+		if _, err := fmt.Fprintln(out, name); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	return out.Close()
+}
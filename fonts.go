@@ -7,6 +7,7 @@ package main
 type font struct {
 	numerals [10][]string
 	percent  []string
+	slash    []string
 	passed   []string
 	tests    []string
 	run      []string
@@ -32,6 +33,7 @@ var fonts = struct {
 			{" ⡔⢢", " ⢈⡹"},
 		},
 		percent: []string{" ⠶⡜", " ⡜⠶"},
+		slash:   []string{"  ⡰", " ⡰ "},
 		passed:  []string{" ⣀⡀ ⢀⣀ ⢀⣀ ⢀⣀ ⢀⡀ ⢀⣸  ", " ⡧⠜ ⠣⠼ ⠭⠕ ⠭⠕ ⠣⠭ ⠣⠼ ⠶"},
 		tests:   []string{" ⣰⡀ ⢀⡀ ⢀⣀ ⣰⡀ ⢀⣀", " ⠘⠤ ⠣⠭ ⠭⠕ ⠘⠤ ⠭⠕"},
 		run:     []string{" ⡀⣀ ⡀⢀ ⣀⡀  ", " ⠏  ⠣⠼ ⠇⠸ ⠶"},
@@ -53,6 +55,7 @@ var fonts = struct {
 			{"┏━┓", "┗━┫", "┗━┛"},
 		},
 		percent: []string{"┏┓╻", "┏━┛", "╹┗┛"},
+		slash:   []string{"  ╱", " ╱ ", "╱  "},
 		passed:  []string{"┏━┓┏━┓┏━┓┏━┓┏━╸╺┳┓ ", "┣━┛┣━┫┗━┓┗━┓┣╸  ┃┃ ", "╹  ╹ ╹┗━┛┗━┛┗━╸╺┻┛╹"},
 		tests:   []string{"╺┳╸┏━╸┏━┓╺┳╸┏━┓", " ┃ ┣╸ ┗━┓ ┃ ┗━┓", " ╹ ┗━╸┗━┛ ╹ ┗━┛"},
 		run:     []string{"┏━┓╻ ╻┏┓╻ ", "┣┳┛┃ ┃┃┗┫ ", "╹┗╸┗━┛╹ ╹╹"},
@@ -63,6 +66,7 @@ var fonts = struct {
 	boring: font{
 		numerals: [10][]string{{"0"}, {"1"}, {"2"}, {"3"}, {"4"}, {"5"}, {"6"}, {"7"}, {"8"}, {"9"}},
 		percent:  []string{"%"},
+		slash:    []string{"/"},
 		passed:   []string{"passed."},
 		tests:    []string{"tests"},
 		run:      []string{"run."},
@@ -73,9 +77,18 @@ var fonts = struct {
 	double: font{
 		numerals: [10][]string{{"０"}, {"１"}, {"２"}, {"３"}, {"４"}, {"５"}, {"６"}, {"７"}, {"８"}, {"９"}},
 		percent:  []string{"％"},
+		slash:    []string{"／"},
 		passed:   []string{"ｐａｓｓｅｄ．"},
 		tests:    []string{"ｔｅｓｔｓ"},
 		run:      []string{"ｒｕｎ．"},
 		space:    "　",
 	},
 }
+
+// number returns the i-th row of n written out in the font.
+func (fnt *font) number(n, i int) string {
+	if n < 10 {
+		return fnt.numerals[n][i]
+	}
+	return fnt.number(n/10, i) + fnt.numerals[n%10][i]
+}
@@ -0,0 +1,195 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// a formatter knows how to write the bits of a results document in
+// some markup language.
+type formatter interface {
+	title(w io.Writer, text string)
+	section(w io.Writer, text string)
+	table(w io.Writer, header []string, rows [][]string)
+	// failure writes the output of a failed test, folded away if the
+	// markup allows for it.
+	failure(w io.Writer, name string, output []string)
+}
+
+var formatters = map[string]formatter{
+	"markdown": markdownFormat{},
+	"org":      orgFormat{},
+	"asciidoc": asciidocFormat{},
+}
+
+type markdownFormat struct{}
+
+func (markdownFormat) title(w io.Writer, text string) {
+	fmt.Fprintf(w, "# %s\n\n", text)
+}
+
+func (markdownFormat) section(w io.Writer, text string) {
+	fmt.Fprintf(w, "## %s\n\n", text)
+}
+
+func (markdownFormat) table(w io.Writer, header []string, rows [][]string) {
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(header)))
+	for _, row := range rows {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+	fmt.Fprintln(w)
+}
+
+func (markdownFormat) failure(w io.Writer, name string, output []string) {
+	fmt.Fprintf(w, "<details><summary><code>%s</code></summary>\n\n```\n", name)
+	for _, line := range output {
+		fmt.Fprint(w, line)
+	}
+	fmt.Fprint(w, "```\n\n</details>\n\n")
+}
+
+type orgFormat struct{}
+
+func (orgFormat) title(w io.Writer, text string) {
+	fmt.Fprintf(w, "* %s\n\n", text)
+}
+
+func (orgFormat) section(w io.Writer, text string) {
+	fmt.Fprintf(w, "** %s\n\n", text)
+}
+
+func (orgFormat) table(w io.Writer, header []string, rows [][]string) {
+	fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+	fmt.Fprintf(w, "|%s|\n", strings.Repeat("-+", len(header)-1)+"-")
+	for _, row := range rows {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | "))
+	}
+	fmt.Fprintln(w)
+}
+
+func (orgFormat) failure(w io.Writer, name string, output []string) {
+	fmt.Fprintf(w, "*** =%s=\n#+BEGIN_SRC text\n", name)
+	for _, line := range output {
+		// org needs these escaped inside blocks
+		if strings.HasPrefix(line, "*") || strings.HasPrefix(line, "#+") {
+			line = "," + line
+		}
+		fmt.Fprint(w, line)
+	}
+	fmt.Fprint(w, "#+END_SRC\n\n")
+}
+
+type asciidocFormat struct{}
+
+func (asciidocFormat) title(w io.Writer, text string) {
+	fmt.Fprintf(w, "= %s\n\n", text)
+}
+
+func (asciidocFormat) section(w io.Writer, text string) {
+	fmt.Fprintf(w, "== %s\n\n", text)
+}
+
+func (asciidocFormat) table(w io.Writer, header []string, rows [][]string) {
+	fmt.Fprintf(w, "[%%header]\n|===\n|%s\n", strings.Join(header, " |"))
+	for _, row := range rows {
+		fmt.Fprintf(w, "|%s\n", strings.Join(row, " |"))
+	}
+	fmt.Fprint(w, "|===\n\n")
+}
+
+func (asciidocFormat) failure(w io.Writer, name string, output []string) {
+	fmt.Fprintf(w, ".`%s`\n[%%collapsible]\n====\n----\n", name)
+	for _, line := range output {
+		fmt.Fprint(w, line)
+	}
+	fmt.Fprint(w, "----\n====\n\n")
+}
+
+type failure struct {
+	name   string
+	output []string
+}
+
+// documentProgress doesn't report progress at all; instead, it waits
+// until the end and writes out a document with the results.
+type documentProgress struct {
+	escape
+	layout
+	sink
+	format   formatter
+	output   map[string][]string
+	failures []failure
+	// filter, if set, preprocesses the output of failures.
+	filter func([]string) []string
+	// label, if set, goes in the title.
+	label string
+}
+
+func (p *documentProgress) fail(name string, output []string) {
+	if p.filter != nil {
+		output = p.filter(output)
+	}
+	p.failures = append(p.failures, failure{name: name, output: output})
+}
+
+func (p *documentProgress) report(ev *TestEvent) {
+	if ev.Test == "" {
+		return
+	}
+	name := ev.name()
+	switch ev.Action {
+	default:
+		if ev.Output != "" {
+			p.output[name] = append(p.output[name], ev.Output)
+		}
+	case "error":
+		name = errorPlaceholder
+		if ev.Output != "" {
+			p.output[name] = append(p.output[name], ev.Output)
+		}
+		p.fail(ev.pkg(), p.output[name])
+		delete(p.output, name)
+	case "fail":
+		p.fail(name, p.output[name])
+		delete(p.output, name)
+	case "pass", "skip", "xfail", "xpass", "flaky":
+		delete(p.output, name)
+	}
+}
+
+func (p *documentProgress) summarize(ss *summary) {
+	w := p.writer()
+	title := "Test results"
+	if p.label != "" {
+		title += ": " + p.label
+	}
+	p.format.title(w, title)
+	if ss.tests.isZero() {
+		fmt.Fprint(w, "No tests were run.\n\n")
+	} else {
+		fmt.Fprintf(w, "%d%% of tests passed.\n\n", (100*ss.tests.passed)/ss.outOf())
+	}
+	row := func(what string, t, p int) []string {
+		return []string{what, num(t), num(p)}
+	}
+	p.format.table(w, []string{"", "Tests", "Packages"}, [][]string{
+		row("Total", ss.tests.total, ss.packages.total),
+		row("Passed", ss.tests.passed, ss.packages.passed),
+		row("Skipped", ss.tests.skipped, ss.packages.skipped),
+		row("Failed", ss.tests.failed, ss.packages.failed),
+		{"Error'ed", "-", num(ss.packages.errored)},
+	})
+	if len(p.failures) == 0 {
+		return
+	}
+	p.format.section(w, "Failures")
+	for _, f := range p.failures {
+		p.format.failure(w, f.name, f.output)
+	}
+}
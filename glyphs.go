@@ -0,0 +1,36 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+// a glyphSet is the set of little pictures used to say how each test
+// or package did.
+type glyphSet struct {
+	pass, fail, skip, err, flaky string
+	// a package that passed, as remembered by go test's cache
+	cached string
+	// quiet mode uses these instead of pass/skip and err
+	dot, dotErr string
+	// how many columns each glyph takes up
+	width int
+}
+
+var glyphSets = map[string]*glyphSet{
+	"default": {
+		pass: "✓", fail: "×", skip: "-", err: "ℯ", flaky: "~", cached: "↺",
+		dot: "•", dotErr: "e",
+		width: 1,
+	},
+	"ascii": {
+		pass: "+", fail: "x", skip: "-", err: "e", flaky: "~", cached: "=",
+		dot: ".", dotErr: "e",
+		width: 1,
+	},
+	// emoji are double-width (and so the others are padded to match)
+	"emoji": {
+		pass: "✅", fail: "❌", skip: "⏭️", err: "💥", flaky: "🎲", cached: "💾",
+		dot: "• ", dotErr: "💥",
+		width: 2,
+	},
+}
@@ -0,0 +1,52 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// readGob reads a gob-encoded []TestEvent (gzipped or not) from the
+// given file, and hands it back as the JSON go test would've written.
+func readGob(path string) (io.Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var evs []TestEvent
+	if err := gob.NewDecoder(r).Decode(&evs); err != nil {
+		return nil, err
+	}
+	return asJSON(evs)
+}
+
+// asJSON writes the events out as go test would've.
+func asJSON(evs []TestEvent) (io.Reader, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range evs {
+		if err := enc.Encode(&evs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return &buf, nil
+}
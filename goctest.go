@@ -6,8 +6,10 @@ package main // import "chipaca.com/goctest"
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -15,7 +17,11 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -48,6 +54,8 @@ for example,
     go test -c ./foo/
     goctest -c foo.test
 
+The ‘-c’ flag can be given more than once, to run each test binary in turn.
+
 If the argument to ‘-c’ is ‘-’, then read plain (non-JSON) input from stdin:
 
     go test -v ./... > tests.out
@@ -56,6 +64,15 @@ If the argument to ‘-c’ is ‘-’, then read plain (non-JSON) input from st
 but note that unless the tests were run with ‘-v’, the output is going to be
 slightly off from wht you'd expect (and even with it, it's not great).
 
+If you'd rather not have to remember which is which, ‘--stdin’ will take a
+peek at what's coming in on stdin and do one or the other.
+
+And ‘--gob FILE’ reads the test events from a file holding a gob-encoded
+[]TestEvent (gzipped or not), e.g. from a test result archive.
+
+To see what goctest looks like without running any tests, ‘--demo’ makes up a
+run with a bit of everything in it, to try the other flags out on.
+
 The above flags should do most of the work already. The remaining flags all
 start with a double dash, with the hopes that this will minimise collisions
 with ‘go test’ itself:
@@ -69,11 +86,301 @@ existing modes are:
   - ‘bare’: no escapes at all; lastly,
   - ‘test’: for testing.
 
+‘--fails-jsonl’: once done, write the failed tests to the given file, one JSON
+object per line with the package, test, output, and elapsed seconds (and the
+‘--label’, if given).
+
+‘--summary-json’: once done, write the counts the summary is made from to the
+given file, as JSON (with the ‘--label’, if given).
+
+‘--merge’: instead of running any tests, add up the files written by
+‘--summary-json’ given as arguments (e.g. one per CI shard), and summarize
+that. The result can itself be written out with ‘--summary-json’.
+
+‘--timings’: once done, write how long each package took to the given file, as
+a JSON array of objects with the package, its elapsed seconds, and how many
+tests it had, slowest first, e.g. for splitting packages between CI shards.
+
+‘--wrap’: on a terminal, wrap long lines in the catalogue of failures at the
+end to fit, indenting what's wrapped. Lines that look like a diff are left be.
+
+‘--write-flaky’: once done, add any flaky tests (implies ‘--flaky’) to the
+given file, unless they're already in it, so it can be used as a baseline.
+
+‘--fail-esc’: like ‘--esc’, but only for the catalogue of failures at the end.
+Defaults to whatever ‘--esc’ ends up being.
+
+‘--flaky’: spot tests that both pass and fail within the same run (e.g. when
+running with ‘-count’), and count them as flaky instead of as both.
+
+‘--porcelain’: instead of the usual, write a line for each test and package
+as it finishes, as ‘STATUS<TAB>PACKAGE<TAB>TEST<TAB>ELAPSED’, for scripts to
+read. STATUS is one of PASS, FAIL, SKIP, ERROR, XFAIL, XPASS or FLAKY; PACKAGE
+is the full import path; TEST is empty for packages; ELAPSED is in seconds.
+This format won't change (though fields might be added at the end).
+
+‘--profile’: when done, say (on stderr) how much time goctest spent waiting
+for the tests versus dealing with their output.
+
+‘--collapse’: show each run of packages that passed as a single line saying how
+many there were, so the ones that didn't stand out.
+
+‘--columns’: with ‘-v’, pack the names of passing tests into columns across
+the terminal, like ‘ls’ does, instead of one per line. Everything else still
+gets a line of its own.
+
+‘--count-parents’: count tests that have subtests as tests in their own right.
+By default only the tests that don't have subtests of their own are counted,
+so that a test with two subtests counts as two tests and not three.
+
+‘--leaves-only’: as well as not counting tests that have subtests (which is the
+default), leave them out of the progress report altogether, e.g. with ‘-v’.
+Whichever of this and ‘--count-parents’ is given last wins.
+
+‘--deterministic’: make goctest's output the same from one run to the next, as
+far as it can, e.g. to compare against a golden file: the disparagement is
+always the same, lists at the end are sorted by name, and there's no clock,
+no sorting by time, nor effective parallelism (nor hints about it). This is
+the default with GOCTEST_ESC=test.
+
+‘--diff-highlight’: in the output of failed tests, colour the lines that look
+like what the test got (or a ‘-’ line in a diff) as failures, and what it
+wanted (or a ‘+’ line) as passes.
+
+‘--dry-run’: don't run anything; instead say (on stderr) what would be run,
+what prefix would be trimmed, and which escapes and font would be used.
+
+‘--echo-command’: before running go, say (on stderr) exactly how it's run.
+
+‘--highlight’: a regular expression whose matches to make stand out in the
+catalogue of failures at the end. Can be given more than once, each getting
+its own colour (or, without colours, wrapped in asterisks).
+
+‘--notice’: a regular expression to look for in the output of tests that pass
+(e.g. ‘deprecated’); matching lines are shown after the run, with their tests.
+Can be given more than once, to look for any of them.
+
+‘--artifact-prefix’: what failed tests say before the path of a file they left
+behind (e.g. ‘ARTIFACT:’, for a screenshot); the files are listed, and linked
+to, with the failure.
+
+‘--label’: a label for the run, e.g. ‘--label nightly-race’, to tell it apart
+from others once archived. It's shown after the summary, in the title of
+‘--format’ documents, and in what ‘--fails-jsonl’ writes.
+
+‘--lazy’: say nothing until the end. If all went well, say so in one line; if
+not, show the progress of the packages that failed, and the rest as usual.
+
+‘--max-package-output’: how many lines to keep, at most, of what's said about
+a package as a whole when it fails (e.g. why it didn't build), or of its own
+output with ‘--pkg-output’. The default, 0, means no limit.
+
+‘--max-output-bytes’: how much test output to hold on to, at most, for showing
+failures. Once over this, the oldest output is dropped. Defaults to 256MiB;
+0 means no limit.
+
+‘--mute’: a comma-separated list of packages to leave out entirely, as if they
+hadn't been run: they're not shown, nor counted. Anything whose full import
+path starts with one of them is muted. Can be given more than once. How many
+packages were muted is said at the end.
+
+‘--sort-pkgs’: if ‘time’, then (without ‘-q’ nor ‘-v’) the line for each
+package is held back until the end, and then they're shown slowest last.
+
+‘--quiet-errors’: instead of a line for each package that failed to build, and
+what the compiler had to say about it, just say how many there were.
+
+‘--since’: only test the packages with files that changed since the given git
+ref (as per ‘git diff’). If that can't be worked out, everything is tested.
+
+‘--only-changed-tests’: only run the tests whose code changed since the ref
+given to ‘--since’ (or, without it, since the last commit). If something else
+changed as well, so there's no telling what tests it affects, it goes by
+package, as with ‘--since’.
+
+‘--watch’: once done, keep an eye on the Go files (and go.mod, go.sum, and
+anything in a testdata directory) under the current directory, and run again
+whenever they change, until interrupted. ‘--poll’ says how often to look
+(250ms by default), and ‘--debounce’ how long things need to stay put after a
+change before running (300ms by default), so that a save made in several
+steps only gets the one run.
+
+‘--watch-run-failed-first’: like ‘--watch’, but if some tests failed, when
+things change run just those first, to see whether they pass now, and then
+all of them.
+
+‘--separate-stderr’: instead of reading what go test writes to stderr along
+with its output, pass it on to goctest's stderr, each line starting with
+‘[go test]’, so that go's own chatter (e.g. ‘go: downloading …’) isn't taken
+for the tests'. With versions of go before 1.24 this includes why packages
+didn't build, which is then left out of the catalogue of failures.
+
+‘--show-build-time’: when done, show the slowest few packages with how long
+they took to build and how long their tests took to run. go test doesn't say
+when building is done, so the build time is really how long it was before
+the package's tests started, which includes waiting for others with ‘-p’.
+
+‘--relative-time’: when done, show the slowest few tests with how long they
+took, and what share that was of the time all the tests took, the bigger the
+redder. Tests with subtests are left out, as their time is their subtests'.
+
+‘--fail-on-skip’: count skipped tests as failing the run (but not packages
+without tests), and list them at the end. With ‘--short-skips-ok’, tests that
+skipped because of ‘-short’ (going by the reason they gave) are left out.
+
+‘--strict-exit’: fail the run if no tests were run (or all of them skipped),
+e.g. because of a typo in a package or a ‘-run’.
+
+‘--shard’: split the packages to test into shards, and only test one of them,
+e.g. ‘--shard 2/4’ for the second of four. The split is always the same for the
+same packages, dealing them out in turn unless ‘--shard-timings’ gives a file
+written by ‘--timings’, in which case they're split to take about as long.
+
+‘--skip-reasons’: after the summary, say why tests were skipped, grouping
+together the tests that skipped for the same reason.
+
+‘--stall’: if go test says nothing for this long (e.g. ‘--stall 60s’), say so
+on stderr, as it might be stuck.
+
+‘--stall-action’: what to do on top of that: ‘warn’ (the default) does nothing
+more, ‘kill’ stops the run.
+
+‘--strict-json’: give up on finding a line that isn't JSON in what the test
+runner says, instead of passing it on as output. Lines saying a package failed
+to build are still allowed, as go test writes those itself.
+
+‘--reverse’: on a terminal, hold back the progress report until the end, and
+show it (inline failures and all) after the summary rather than before it.
+
+‘--strip-ansi’: take any escape sequences (e.g. colours) out of what the tests
+themselves write, before goctest does anything with it. Handy with ‘-c -’.
+
+‘--summary’: where to put the summary: ‘bottom’ (the default) is after the
+progress report; ‘top’ clears the screen first so the summary is at the top,
+with the progress report shown again and the catalogue of failures under it
+(on a terminal only); and ‘both’ also repeats a compact summary after the
+failures.
+
+‘--testify’: in the output of failed tests, boil testify's reports of failed
+assertions down to the error, with what was expected and what was got made to
+stand out. Output that doesn't look like testify's is left as it is.
+
+‘--thousands’: what to separate thousands with in the numbers in the summary,
+e.g. ‘--thousands ,’, or ‘--thousands ""’ for nothing. The default comes from
+the locale (LC_ALL, LC_NUMERIC, or LANG), with nothing for C or POSIX, or when
+‘--deterministic’.
+
+‘--timestamps’: with ‘-v’, start each line with the time the test runner said
+the event happened (or, failing that, when goctest heard about it).
+
+‘--tiny’: say nothing but how many tests passed, in a single line at the end,
+e.g. for a status bar.
+
 ‘--trim’: allows you to specify a prefix to remove from package names.
-If not given it defaults to the output of ‘go list -m’. If that fails (e.g.
-because you're not running in a module) it's adjusted on the fly to be the
-longest common prefix of package names reported by the test runner. This
-means the very first test will get it wrong. In a pinch you can ‘--trim ""’.
+If not given it defaults to the output of ‘go list -m’ (remembered for each
+directory in $XDG_STATE_HOME/goctest, until go.mod changes), unless reading
+from stdin. If that fails (e.g. because you're not running in a module) it's
+adjusted on the fly to be the longest common prefix of package names reported
+by the test runner. This means the very first test will get it wrong.
+
+‘--no-build-output’: don't show what the compiler has to say about packages that
+fail to build as it says it, only with the rest of the failures at the end.
+
+‘--no-trim’: show package names in full, as the test runner reports them.
+
+‘--baseline’: takes a file listing tests that are known to fail, one per line,
+as goctest shows them (e.g. ‘…/foo:TestBar’). These failing is not treated as
+a failure, and if any of them pass you'll be told so you can take them out.
+
+‘--env-info’: when done, say what version of Go this was run with, on what,
+and how goctest was run, so the results can be told apart from others'.
+
+‘--filter’: preprocess the output of failed tests before it's shown, either
+with a substitution like ‘s/regexp/replacement/’ (as per Go's regexp package),
+or by piping it through a shell command (which gets 10 seconds to do so).
+
+‘--format’: instead of reporting progress as it happens, write a document with
+the results once done. The document can be in ‘markdown’, ‘org’, or ‘asciidoc’.
+
+‘--glyphs’: which set of glyphs to use to show how each test or package did:
+‘default’, ‘ascii’, or ‘emoji’.
+
+‘--animate’: on a terminal, instead of the clock ticking away in the corner,
+show a spinner (and the clock) only when the tests have gone quiet for a bit,
+e.g. while building.
+
+‘--also-plain’: as well as reporting progress as usual, write a plain report
+(without any escape codes) to the given file, failures and all.
+
+‘--banner’: how the big banner at the end shows how many tests passed: as a
+‘percent’ (the default), a ‘fraction’ (e.g. 42/45), or ‘both’.
+
+‘--banner-size’: how big the banner is: ‘small’, ‘normal’ (the default, which
+depends on how progress is reported), or ‘large’. The one-line banners (as
+with ‘-q’) only go as big as they already are.
+
+‘--green-at’ and ‘--red-at’: the percentage of passed tests from which the
+banner is all green (100 by default), and up to which it's all red (0 by
+default); in between it goes from one to the other.
+
+‘--pct-denominator’: what the banner takes the passed tests out of: the
+‘non-skipped’ ones (the default), or the ‘total’, so skipped tests count
+against it. Tests that failed as expected are left out either way.
+
+‘--keep-going’: in a go workspace, test each module of the workspace in turn,
+carrying on past modules that fail to build.
+
+‘--context-tests’: for the first test to fail, say which tests (up to this
+many) finished just before it in its package, in case one of them left a
+mess behind that tripped it up.
+
+‘--max-fails’: stop the run once this many tests have failed. The default, 0,
+means to never give up.
+
+‘--owners’: takes a file mapping package path prefixes to the teams that own
+them, one prefix and team per line, and says how many of the failing packages
+belong to each team.
+
+‘--pager’: show the catalogue of failures at the end through $PAGER (or less),
+if on a terminal.
+
+‘--pkg-output’: after the summary, show what packages wrote outside of any one
+test (e.g. from TestMain), even if they passed.
+
+‘--pretty-panics’: trim the goroutine dumps of panicking tests down to the
+frames that are in the code being tested.
+
+‘--pkg-deadline’: how long a package's tests should take, at most (e.g. 30s);
+any that take longer are pointed out after the summary. Unlike go test's
+‘-timeout’, nothing is stopped.
+
+‘--pretty-leaks’: boil the goroutines goleak complains about in failed tests
+down to what each was doing, and where it was started from. Tests that leaked
+goroutines are listed after the summary either way.
+
+‘--hide-empty’: don't show packages without tests as they happen; they're
+still counted in the summary, as skipped packages.
+
+‘--quiet-skips’: don't show skipped tests, nor packages without tests, as they
+happen; they're still counted in the summary.
+
+‘--rerun-cmd-only’: say nothing but, if anything failed, the ‘go test’ command
+to run just the failed tests again (which is otherwise shown after the
+catalogue of failures), for use in scripts.
+
+‘--version’: print goctest's version (and what it knows about how it was
+built), and exit.
+
+goctest exits with status 1 if any tests failed, or any packages failed or
+didn't build; tests that failed as expected (see ‘--baseline’), or that were
+flaky (see ‘--flaky’), don't count. If go test itself failed without saying
+why, goctest exits with its status. Should goctest itself trip up while reading
+go test's output, it passes the rest of it through as it comes, and exits with
+status 1.
+
+If a run seems stuck, send goctest a SIGQUIT (e.g. ‘kill -QUIT’) and it will
+say which tests and packages are still running, and carry on. Note that
+Ctrl-\ sends it to go test as well, which then dumps its goroutines and stops.
 
 Lastly, the ‘--’ flag tells goctest to stop looking at its arguments and get
 on with it.
@@ -85,15 +392,18 @@ Run ‘go help test’ and ‘go help testflag’ for details.
 )
 
 type TestEvent struct {
+	Time    time.Time // encodes as an RFC3339-format string
 	Action  string
 	Package string
 	Test    string
 	Output  string
-	// these fields are there in the JSON (some of the time!) but we don't use them so why bother
-	//   Time    time.Time // encodes as an RFC3339-format string
-	//   Elapsed float64 // seconds
+	Elapsed float64 // seconds
+	// newer go test also says how building went
+	ImportPath  string
+	FailedBuild string
 	// private stuff sneakily piggybacking
 	prefix string
+	cached bool
 }
 
 func (ev *TestEvent) pkg() string {
@@ -115,6 +425,22 @@ func (ev *TestEvent) name() string {
 	return pkg + ":" + ev.Test
 }
 
+// sameAs returns whether the two events are the same, whenever they happened.
+func (ev *TestEvent) sameAs(other *TestEvent) bool {
+	a, b := *ev, *other
+	a.Time, b.Time = time.Time{}, time.Time{}
+	return a == b
+}
+
+// isResult returns whether the event is the outcome of a test or package.
+func (ev *TestEvent) isResult() bool {
+	switch ev.Action {
+	case "pass", "fail", "skip":
+		return true
+	}
+	return false
+}
+
 func (ev *TestEvent) isTest() bool {
 	return ev.Test != "" && ev.Test != errorPlaceholder
 }
@@ -125,6 +451,13 @@ type sums struct {
 	errored int
 	skipped int
 	passed  int
+	// expected failures, and unexpected passes (see baseline)
+	xfailed int
+	xpassed int
+	// tests that both passed and failed (see flakes)
+	flaky int
+	// passes that go test got from its cache
+	cached int
 }
 
 func (s *sums) addFail() {
@@ -147,32 +480,181 @@ func (s *sums) addPass() {
 	s.passed++
 }
 
+func (s *sums) addXFail() {
+	s.xfailed++
+	s.total++
+}
+
+func (s *sums) addXPass() {
+	s.xpassed++
+	s.addPass()
+}
+
+func (s *sums) addFlaky() {
+	s.flaky++
+	s.total++
+}
+
+// remove undoes an earlier add of the given action.
+func (s *sums) remove(action string) {
+	switch action {
+	case "pass":
+		s.passed--
+	case "fail":
+		s.failed--
+	case "flaky":
+		s.flaky--
+	default:
+		return
+	}
+	s.total--
+}
+
+// counted is how many of the total count towards the pass percentage
+// (skips and expected failures don't).
+func (s *sums) counted() int {
+	return s.total - s.skipped - s.xfailed
+}
+
 func (s *sums) isZero() bool {
-	return s.total-s.skipped <= 0
+	return s.counted() <= 0
 }
 
 type summary struct {
 	tests    sums
 	packages sums
+	// parents are the tests that have been seen to have subtests; they
+	// are just there to group their subtests, so they don't count
+	// (unless countParents is set).
+	parents      map[string]bool
+	countParents bool
+	// countSkips puts skipped tests in what the ratio of passed tests
+	// is taken out of, so that they count against it.
+	countSkips bool
+	// banner is how big shows the ratio of passed tests: as a
+	// ‘percent’ (the default), a ‘fraction’, or ‘both’.
+	banner string
+	// size is how big the banner is: ‘small’, ‘normal’, or ‘large’.
+	size string
+	// greenAt and redAt are the percentages of passed tests from which
+	// (and up to which) the banner is all green (or all red).
+	greenAt, redAt int
+	// busy is how long packages took, all added up, and slowest how
+	// long the slowest one took; first and last are when the first
+	// and last events happened.
+	busy, slowest float64
+	first, last   time.Time
+	// paused is how many tests called t.Parallel.
+	paused int
+	// flakyPkgs are the packages with flaky tests, and excused how many
+	// packages failed with nothing but flaky tests to show for it.
+	flakyPkgs map[string]bool
+	excused   int
+}
+
+// noteParents marks the ancestors of the event's test, if any, as parents.
+func (ss *summary) noteParents(ev *TestEvent) {
+	if ss.countParents || !ev.isTest() {
+		return
+	}
+	if ss.parents == nil {
+		ss.parents = map[string]bool{}
+	}
+	name := ev.Test
+	for {
+		idx := strings.LastIndexByte(name, '/')
+		if idx < 0 {
+			return
+		}
+		name = name[:idx]
+		key := ev.Package + ":" + name
+		if ss.parents[key] {
+			return
+		}
+		ss.parents[key] = true
+	}
+}
+
+// isParent returns whether the event's test has been seen to have subtests.
+func (ss *summary) isParent(ev *TestEvent) bool {
+	return ss.parents[ev.Package+":"+ev.Test]
 }
 
 func (ss *summary) add(ev *TestEvent) {
+	ss.noteParents(ev)
+	if !ev.Time.IsZero() {
+		if ss.first.IsZero() || ev.Time.Before(ss.first) {
+			ss.first = ev.Time
+		}
+		if ev.Time.After(ss.last) {
+			ss.last = ev.Time
+		}
+	}
+	if ev.Test == "" && ev.isResult() {
+		ss.busy += ev.Elapsed
+		if ev.Elapsed > ss.slowest {
+			ss.slowest = ev.Elapsed
+		}
+	}
+	if ev.Action == "pause" {
+		ss.paused++
+	}
 	var s *sums
 	if ev.Test == "" || ev.Test == errorPlaceholder {
 		s = &ss.packages
+	} else if ss.isParent(ev) {
+		return
 	} else {
 		s = &ss.tests
 	}
 	switch ev.Action {
 	case "pass":
 		s.addPass()
+		if ev.cached {
+			s.cached++
+		}
 	case "skip":
 		s.addSkip()
 	case "fail":
 		s.addFail()
 	case "error":
 		s.addError()
+	case "xfail":
+		s.addXFail()
+	case "xpass":
+		s.addXPass()
+	case "flaky":
+		s.addFlaky()
+		if s == &ss.tests {
+			if ss.flakyPkgs == nil {
+				ss.flakyPkgs = map[string]bool{}
+			}
+			ss.flakyPkgs[ev.Package] = true
+		}
+	}
+	if s == &ss.packages && ev.Action == "fail" && ss.flakyPkgs[ev.Package] {
+		ss.excused++
+	}
+}
+
+// parallelism is how many packages were being tested at once, on
+// average; 0 if that can't be told, or isn't worth telling (there's only
+// the one package, or it was all over in under a second of testing).
+func (ss *summary) parallelism() float64 {
+	wall := ss.last.Sub(ss.first).Seconds()
+	if ss.packages.total < 2 || ss.busy < 1 || wall <= 0 {
+		return 0
+	}
+	return ss.busy / wall
+}
+
+// outOf is what the ratio of passed tests is taken out of.
+func (ss *summary) outOf() int {
+	n := ss.tests.counted()
+	if ss.countSkips {
+		n += ss.tests.skipped
 	}
+	return n
 }
 
 func (ss *summary) isZero() bool {
@@ -187,28 +669,31 @@ func (ss *summary) big(esc *escape, fnt *font) []string {
 	var lines []string
 	p := 0
 	if !ss.tests.isZero() {
-		p = (100 * ss.tests.passed) / (ss.tests.total - ss.tests.skipped)
+		p = (100 * ss.tests.passed) / ss.outOf()
 		if p > 100 {
 			p = 100
 		} else if p < 0 {
 			p = 0
 		}
 	}
-	d := p % 10
-	r := p / 10
 	for i := range fnt.numerals[0] {
 		var line []string
 		if ss.tests.isZero() {
 			line = []string{esc.zero + fnt.numerals[0][i], fnt.tests[i], fnt.run[i] + esc.endc}
 		} else {
-			line = []string{esc.rgb(colourForRatio(ss.tests.passed, ss.tests.total-ss.tests.skipped))}
-			if p == 100 {
-				line[0] += fnt.numerals[1][i] + fnt.numerals[0][i] + fnt.numerals[0][i] + fnt.percent[i]
-			} else {
-				if r > 0 {
-					line[0] += fnt.numerals[r][i]
+			line = []string{esc.rgb(ss.colour(ss.tests.passed, ss.outOf()))}
+			if ss.banner == "fraction" || ss.banner == "both" {
+				passed := ss.tests.passed
+				if passed < 0 {
+					passed = 0
+				}
+				line[0] += fnt.number(passed, i) + fnt.slash[i] + fnt.number(ss.outOf(), i)
+				if ss.banner == "both" {
+					line[0] += fnt.space
 				}
-				line[0] += fnt.numerals[d][i] + fnt.percent[i]
+			}
+			if ss.banner != "fraction" {
+				line[0] += fnt.number(p, i) + fnt.percent[i]
 			}
 			line = append(line, fnt.tests[i], fnt.passed[i]+esc.endc)
 		}
@@ -217,6 +702,58 @@ func (ss *summary) big(esc *escape, fnt *font) []string {
 	return lines
 }
 
+// font picks the font for the banner, out of what a reporter would use
+// for each size.
+func (ss *summary) font(small, normal, large *font) *font {
+	switch ss.size {
+	case "small":
+		return small
+	case "large":
+		return large
+	}
+	return normal
+}
+
+// compact builds a one-line summary of the test run.
+func (ss *summary) compact(esc *escape) string {
+	var s []string
+	if ss.tests.skipped > 0 {
+		s = append(s, fmt.Sprintf("%s %sskipped%s", num(ss.tests.skipped), esc.skip, esc.endc))
+	}
+	if ss.tests.failed > 0 {
+		s = append(s, fmt.Sprintf("%s %sfailed%s", num(ss.tests.failed), esc.fail, esc.endc))
+	}
+	if ss.tests.xfailed > 0 {
+		s = append(s, fmt.Sprintf("%s %sfailed as expected%s", num(ss.tests.xfailed), esc.skip, esc.endc))
+	}
+	if ss.tests.flaky > 0 {
+		s = append(s, fmt.Sprintf("%s %sflaky%s", num(ss.tests.flaky), esc.zero, esc.endc))
+	}
+	if ss.tests.passed > 0 {
+		s = append(s, fmt.Sprintf("%s %spassed%s", num(ss.tests.passed), esc.pass, esc.endc))
+	}
+	line := ""
+	if len(s) > 0 {
+		line = strings.Join(s, ", ") + ". "
+	}
+	return line + "  " + ss.big(esc, ss.font(&fonts.boring, &fonts.double, &fonts.double))[0]
+}
+
+// colour is colourForRatio with the ratio stretched so that redAt is
+// as red as it gets, and greenAt as green.
+func (ss *summary) colour(p, q int) [3]uint8 {
+	if ss.greenAt <= ss.redAt {
+		// not set
+		return colourForRatio(p, q)
+	}
+	p, q = 100*p-ss.redAt*q, (ss.greenAt-ss.redAt)*q
+	if p >= q {
+		return colourForRatio(1, 1)
+	}
+	// the last of the 9 steps is kept for greenAt and up
+	return colourForRatio(8*p, 9*q)
+}
+
 // returns a colour suitable for highlighting a ratio of passed to
 // total tests.
 // only bit tht uses 24-bit colour support
@@ -255,23 +792,102 @@ type progressReporter interface {
 	report(*TestEvent)
 	summarize(*summary)
 	setEscape(string) *escape
+	setLayout(int, *glyphSet)
+	setOutput(io.Writer)
+}
+
+// a sink is where a reporter writes to: stdout, unless told otherwise.
+type sink struct {
+	out io.Writer
+}
+
+func (s *sink) setOutput(w io.Writer) {
+	s.out = w
+}
+
+func (s *sink) writer() io.Writer {
+	if s.out == nil {
+		return os.Stdout
+	}
+	return s.out
 }
 
-type defaultProgress struct{ escape }
+type defaultProgress struct {
+	escape
+	layout
+	sink
+	// if sortByTime, package lines are held back until the end, and
+	// then shown slowest last
+	sortByTime bool
+	deferred   []TestEvent
+	// if collapse, runs of passing packages are shown as a single line
+	collapse  bool
+	passes    int
+	firstPass TestEvent
+}
 
 func (p *defaultProgress) report(ev *TestEvent) {
 	if ev.isTest() {
 		return
 	}
+	if p.sortByTime && ev.Action != "output" {
+		p.deferred = append(p.deferred, *ev)
+		return
+	}
+	p.line(ev)
+}
+
+func (p *defaultProgress) line(ev *TestEvent) {
+	g := p.glyph()
+	pkg := p.fit(ev.pkg(), 0)
+	if p.collapse {
+		switch ev.Action {
+		case "pass":
+			if p.passes == 0 {
+				p.firstPass = *ev
+			}
+			p.passes++
+			return
+		case "skip", "fail", "error", "xfail":
+			p.flush()
+		}
+	}
 	switch ev.Action {
 	case "pass":
-		fmt.Println(p.pass+"✓"+p.endc, ev.pkg())
+		p.passed(ev)
 	case "skip":
-		fmt.Printf("%s- %s%s\n", p.skip, ev.pkg(), p.endc)
+		fmt.Fprintf(p.writer(), "%s%s %s%s\n", p.skip, g.skip, pkg, p.endc)
 	case "fail":
-		fmt.Println(p.fail+"×"+p.endc, ev.pkg())
+		fmt.Fprintln(p.writer(), p.fail+g.fail+p.endc, pkg)
 	case "error":
-		fmt.Printf("%sℯ %s%s\n", p.fail, ev.pkg(), p.endc)
+		fmt.Fprintf(p.writer(), "%s%s %s%s\n", p.fail, g.err, pkg, p.endc)
+	case "xfail":
+		fmt.Fprintf(p.writer(), "%s%s %s (expected failure)%s\n", p.skip, g.fail, p.fit(ev.pkg(), 19), p.endc)
+	}
+}
+
+// passed writes the line for a package that passed, which looks
+// different if go test didn't actually run it.
+func (p *defaultProgress) passed(ev *TestEvent) {
+	g := p.glyph()
+	pkg := p.fit(ev.pkg(), 0)
+	if ev.cached {
+		fmt.Fprintln(p.writer(), p.zero+g.cached+p.endc, pkg)
+	} else {
+		fmt.Fprintln(p.writer(), p.pass+g.pass+p.endc, pkg)
+	}
+}
+
+// flush writes out the run of passing packages held back by collapse.
+func (p *defaultProgress) flush() {
+	n := p.passes
+	p.passes = 0
+	switch n {
+	case 0:
+	case 1:
+		p.passed(&p.firstPass)
+	default:
+		fmt.Fprintf(p.writer(), "%s%s%s %s packages passed\n", p.pass, p.glyph().pass, p.endc, num(n))
 	}
 }
 
@@ -281,87 +897,220 @@ func gn(s, p string) func(int) string {
 		if n == 1 {
 			x = s
 		}
-		return fmt.Sprintf("%d %s", n, x)
+		return fmt.Sprintf("%s %s", num(n), x)
 	}
 }
 
 func (p *defaultProgress) summarize(ss *summary) {
+	sort.SliceStable(p.deferred, func(i, j int) bool {
+		return p.deferred[i].Elapsed < p.deferred[j].Elapsed
+	})
+	for i := range p.deferred {
+		p.line(&p.deferred[i])
+	}
+	p.flush()
 	pkg := gn("package", "packages")
 	tst := gn("test", "tests")
-	fmt.Printf("Found %s in %s", tst(ss.tests.total), pkg(ss.packages.total))
+	fmt.Fprintf(p.writer(), "Found %s in %s", tst(ss.tests.total), pkg(ss.packages.total))
+	var notes []string
 	if ss.packages.skipped > 0 {
-		fmt.Printf(" (%s had %sNO tests%s)", pkg(ss.packages.skipped), p.skip, p.endc)
+		notes = append(notes, fmt.Sprintf("%s had %sNO tests%s", pkg(ss.packages.skipped), p.skip, p.endc))
+	}
+	if ss.packages.cached > 0 {
+		notes = append(notes, fmt.Sprintf("%s %scached%s", num(ss.packages.cached), p.zero, p.endc))
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(p.writer(), " (%s)", strings.Join(notes, ", "))
 	}
 	if ss.packages.errored > 0 {
-		fmt.Printf(", and %d packages did not even build", ss.packages.errored)
+		fmt.Fprintf(p.writer(), ", and %s packages did not even build", num(ss.packages.errored))
 	}
-	if ss.tests.total > 0 {
-		fmt.Printf(".\n%d tests %spassed%s", ss.tests.passed, p.pass, p.endc)
+	switch {
+	case ss.tests.total > 0 && ss.tests.isZero():
+		// all skipped (or failed as expected); ‘0 tests passed’ would
+		// make it sound worse than it is
+		fmt.Fprintf(p.writer(), ".\nNo tests were %srun%s", p.zero, p.endc)
+		if ss.tests.skipped > 0 {
+			fmt.Fprintf(p.writer(), " (%s tests were %sskipped%s)", num(ss.tests.skipped), p.skip, p.endc)
+		}
+		if ss.tests.xfailed > 0 {
+			fmt.Fprintf(p.writer(), " (%s tests %sfailed as expected%s)", num(ss.tests.xfailed), p.skip, p.endc)
+		}
+	case ss.tests.total > 0:
+		fmt.Fprintf(p.writer(), ".\n%s tests %spassed%s", num(ss.tests.passed), p.pass, p.endc)
 		if ss.tests.failed > 0 {
-			fmt.Printf(", and %d tests %sfailed%s", ss.tests.failed, p.fail, p.endc)
+			fmt.Fprintf(p.writer(), ", and %s tests %sfailed%s", num(ss.tests.failed), p.fail, p.endc)
 		}
 		if ss.tests.skipped > 0 {
-			fmt.Printf(" (%d tests were %sskipped%s)", ss.tests.skipped, p.skip, p.endc)
+			fmt.Fprintf(p.writer(), " (%s tests were %sskipped%s)", num(ss.tests.skipped), p.skip, p.endc)
+		}
+		if ss.tests.xfailed > 0 {
+			fmt.Fprintf(p.writer(), ", and %s tests %sfailed as expected%s", num(ss.tests.xfailed), p.skip, p.endc)
+		}
+		if ss.tests.xpassed > 0 {
+			fmt.Fprintf(p.writer(), " (%s tests passed %sunexpectedly%s)", num(ss.tests.xpassed), p.pass, p.endc)
+		}
+		if ss.tests.flaky > 0 {
+			fmt.Fprintf(p.writer(), ", and %s tests were %sflaky%s", num(ss.tests.flaky), p.zero, p.endc)
 		}
 	}
-	fmt.Println(".")
+	fmt.Fprintln(p.writer(), ".")
 
-	for _, line := range ss.big(&p.escape, &fonts.braille) {
-		fmt.Println(line)
+	for _, line := range ss.big(&p.escape, ss.font(&fonts.double, &fonts.braille, &fonts.future)) {
+		fmt.Fprintln(p.writer(), line)
 	}
 }
 
 type verboseProgress struct {
 	escape
+	layout
+	sink
 	seenFails map[string]bool
+	// if timestamps, each line starts with when the event happened
+	timestamps bool
+	// if columns, passing tests are held back and then packed into
+	// as many columns as fit, whenever anything else happens.
+	columns bool
+	passes  []string
+}
+
+// packPasses writes out the passing tests held back so far, in columns.
+func (p *verboseProgress) packPasses() {
+	if len(p.passes) == 0 {
+		return
+	}
+	g := p.glyph()
+	for _, row := range columnize(p.passes, p.width, g.width+1, 2) {
+		for _, cell := range row {
+			fmt.Fprint(p.writer(), p.pass+g.pass+p.endc, " ", cell)
+		}
+		fmt.Fprintln(p.writer())
+	}
+	p.passes = p.passes[:0]
+}
+
+// stamp returns the time of the event to put at the start of a line,
+// if so wanted, and how many columns that takes up.
+func (p *verboseProgress) stamp(ev *TestEvent) (string, int) {
+	if !p.timestamps {
+		return "", 0
+	}
+	when := ev.Time
+	if when.IsZero() {
+		when = time.Now()
+	}
+	ts := when.Local().Format("15:04:05.000 ")
+	return p.skip + ts + p.endc, len(ts)
 }
 
 func (p *verboseProgress) report(ev *TestEvent) {
+	if p.columns {
+		if ev.Action == "pass" && ev.Test != "" {
+			p.passes = append(p.passes, p.fit(ev.name(), 0))
+			return
+		}
+		switch ev.Action {
+		case "pass", "skip", "fail", "error", "xfail", "xpass", "flaky":
+			p.packPasses()
+		}
+	}
+	g := p.glyph()
+	ts, n := p.stamp(ev)
+	name := p.fit(ev.name(), n)
+	pkg := p.fit(ev.pkg(), n)
 	switch ev.Action {
 	case "pass":
 		if ev.Test != "" {
-			fmt.Println(p.pass+"✓"+p.endc, ev.name())
+			fmt.Fprintln(p.writer(), ts+p.pass+g.pass+p.endc, name)
 		}
 	case "skip":
 		if ev.Test != "" {
-			fmt.Printf("%s- %s%s\n", p.skip, ev.name(), p.endc)
+			fmt.Fprintf(p.writer(), "%s%s%s %s%s\n", ts, p.skip, g.skip, name, p.endc)
 		} else {
-			fmt.Printf("%s- %s%s\n", p.skip, ev.pkg(), p.endc)
+			fmt.Fprintf(p.writer(), "%s%s%s %s%s\n", ts, p.skip, g.skip, pkg, p.endc)
 		}
 	case "fail":
 		if ev.Test != "" {
 			if ev.Package != "" {
 				p.seenFails[ev.Package] = true
 			}
-			fmt.Println(p.fail+"×"+p.endc, ev.name())
+			fmt.Fprintln(p.writer(), ts+p.fail+g.fail+p.endc, name)
 		} else if !p.seenFails[ev.Package] {
-			fmt.Println(p.fail+"×"+p.endc, ev.pkg())
+			fmt.Fprintln(p.writer(), ts+p.fail+g.fail+p.endc, pkg)
 		}
 	case "error":
-		fmt.Println(p.fail+"ℯ"+p.endc, ev.pkg())
+		fmt.Fprintln(p.writer(), ts+p.fail+g.err+p.endc, pkg)
+	case "xfail":
+		if ev.Test != "" {
+			fmt.Fprintf(p.writer(), "%s%s%s %s (expected failure)%s\n", ts, p.skip, g.fail, p.fit(ev.name(), n+19), p.endc)
+		} else {
+			fmt.Fprintf(p.writer(), "%s%s%s %s (expected failure)%s\n", ts, p.skip, g.fail, p.fit(ev.pkg(), n+19), p.endc)
+		}
+	case "xpass":
+		fmt.Fprintln(p.writer(), ts+p.pass+g.pass+p.endc, p.fit(ev.name(), n+23), p.em("(unexpectedly passing)"))
+	case "flaky":
+		fmt.Fprintf(p.writer(), "%s%s%s %s (flaky)%s\n", ts, p.zero, g.flaky, p.fit(ev.name(), n+8), p.endc)
 	}
 }
 
 func (p *verboseProgress) summarize(ss *summary) {
+	p.packPasses()
 	if ss.isZero() {
-		for _, line := range ss.big(&p.escape, &fonts.future) {
-			fmt.Println(line)
+		for _, line := range ss.big(&p.escape, ss.font(&fonts.braille, &fonts.future, &fonts.future)) {
+			fmt.Fprintln(p.writer(), line)
 		}
 		return
 	}
-	big := ss.big(&p.escape, &fonts.future) // here we (ab)use that future is 3 rows tall
-	var w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	big := ss.big(&p.escape, ss.font(&fonts.braille, &fonts.future, &fonts.future))
+	for len(big) < 3 {
+		// it goes alongside the three rows that follow
+		big = append(big, "")
+	}
+	var w = tabwriter.NewWriter(p.writer(), 0, 4, 2, ' ', tabwriter.AlignRight)
 	fmt.Fprintln(w, p.nope+"\t\tTests\tPackages\t"+p.endc+"\t")
-	fmt.Fprintf(w, "%s\tTotal\t%d \t%d \t%s\t\n", p.nope, ss.tests.total, ss.packages.total, p.endc)
-	fmt.Fprintf(w, "%s\tPassed\t%d \t%d \t%s\t  %s\n", p.pass, ss.tests.passed, ss.packages.passed, p.endc, big[0])
-	fmt.Fprintf(w, "%s\tSkipped\t%d \t%d \t%s\t  %s\n", p.skip, ss.tests.skipped, ss.packages.skipped, p.endc, big[1])
-	fmt.Fprintf(w, "%s\tFailed\t%d \t%d \t%s\t  %s\n", p.fail, ss.tests.failed, ss.packages.failed, p.endc, big[2])
-	fmt.Fprintf(w, "%s\tError'ed\t - \t%d \t%s\t\n", p.fail, ss.packages.errored, p.endc)
+	fmt.Fprintf(w, "%s\tTotal\t%s \t%s \t%s\t\n", p.nope, num(ss.tests.total), num(ss.packages.total), p.endc)
+	fmt.Fprintf(w, "%s\tPassed\t%s \t%s \t%s\t  %s\n", p.pass, num(ss.tests.passed), num(ss.packages.passed), p.endc, big[0])
+	fmt.Fprintf(w, "%s\tSkipped\t%s \t%s \t%s\t  %s\n", p.skip, num(ss.tests.skipped), num(ss.packages.skipped), p.endc, big[1])
+	fmt.Fprintf(w, "%s\tFailed\t%s \t%s \t%s\t  %s\n", p.fail, num(ss.tests.failed), num(ss.packages.failed), p.endc, big[2])
+	fmt.Fprintf(w, "%s\tError'ed\t - \t%s \t%s\t\n", p.fail, num(ss.packages.errored), p.endc)
+	if ss.tests.xfailed > 0 || ss.tests.xpassed > 0 {
+		fmt.Fprintf(w, "%s\tXFailed\t%s \t%s \t%s\t\n", p.skip, num(ss.tests.xfailed), num(ss.packages.xfailed), p.endc)
+		fmt.Fprintf(w, "%s\tXPassed\t%s \t - \t%s\t\n", p.pass, num(ss.tests.xpassed), p.endc)
+	}
+	if ss.packages.cached > 0 {
+		fmt.Fprintf(w, "%s\tCached\t - \t%s \t%s\t\n", p.zero, num(ss.packages.cached), p.endc)
+	}
+	if ss.tests.flaky > 0 {
+		fmt.Fprintf(w, "%s\tFlaky\t%s \t - \t%s\t\n", p.zero, num(ss.tests.flaky), p.endc)
+	}
 	w.Flush()
+	p.legend(ss)
+}
+
+// legend says what the colours in the summary table mean, for when
+// they're seen out of context (e.g. in a screenshot). With no colours
+// there's nothing to explain.
+func (p *verboseProgress) legend(ss *summary) {
+	if p.pass == "" {
+		return
+	}
+	g := p.glyph()
+	key := []string{
+		p.pass + g.pass + " passed" + p.endc,
+		p.skip + g.skip + " skipped" + p.endc,
+		p.fail + g.fail + " failed" + p.endc,
+		p.fail + g.err + " error'ed" + p.endc,
+	}
+	if ss.packages.cached > 0 || ss.tests.flaky > 0 {
+		key = append(key, p.zero+g.flaky+" cached or flaky"+p.endc)
+	}
+	fmt.Fprintln(p.writer(), "Key:", strings.Join(key, "  "))
 }
 
 type quietProgress struct {
 	escape
+	layout
+	sink
 	needsNL bool
 }
 
@@ -369,57 +1118,86 @@ func (p *quietProgress) report(ev *TestEvent) {
 	if ev.isTest() {
 		return
 	}
+	g := p.glyph()
 	switch ev.Action {
 	case "pass":
-		fmt.Print(p.pass, "•", p.endc)
+		fmt.Fprint(p.writer(), p.pass, g.dot, p.endc)
 		p.needsNL = true
 	case "skip":
-		fmt.Print(p.skip, "•", p.endc)
+		fmt.Fprint(p.writer(), p.skip, g.dot, p.endc)
 		p.needsNL = true
 	case "fail":
-		fmt.Printf("%s%s%s", p.fail, p.uri(ev.pkg(), "×"), p.endc)
+		fmt.Fprintf(p.writer(), "%s%s%s", p.fail, p.uri(ev.pkg(), g.fail), p.endc)
 		p.needsNL = true
 	case "error":
-		fmt.Printf("%s%s%s", p.fail, p.uri(ev.pkg(), "e"), p.endc)
+		fmt.Fprintf(p.writer(), "%s%s%s", p.fail, p.uri(ev.pkg(), g.dotErr), p.endc)
+	case "xfail":
+		fmt.Fprint(p.writer(), p.skip, g.fail, p.endc)
+		p.needsNL = true
 	}
 }
 
 func (p *quietProgress) summarize(ss *summary) {
 	if p.needsNL {
-		fmt.Println()
-	}
-	var s []string
-	if ss.tests.skipped > 0 {
-		s = append(s, fmt.Sprintf("%d %sskipped%s", ss.tests.skipped, p.skip, p.endc))
+		fmt.Fprintln(p.writer())
 	}
-	if ss.tests.failed > 0 {
-		s = append(s, fmt.Sprintf("%d %sfailed%s", ss.tests.failed, p.fail, p.endc))
-	}
-	if ss.tests.passed > 0 {
-		s = append(s, fmt.Sprintf("%d %spassed%s", ss.tests.passed, p.pass, p.endc))
-	}
-	if len(s) > 0 {
-		fmt.Print(strings.Join(s, ", "), ". ")
-	}
-	fmt.Println(" ", ss.big(&p.escape, &fonts.double)[0])
+	fmt.Fprintln(p.writer(), ss.compact(&p.escape))
+}
+
+// tinyProgress says nothing but the one line of banner at the end, for
+// putting in a status bar or some such.
+type tinyProgress struct {
+	escape
+	layout
+	sink
+}
+
+func (p *tinyProgress) report(*TestEvent) {}
+
+func (p *tinyProgress) summarize(ss *summary) {
+	fmt.Fprintln(p.writer(), ss.big(&p.escape, ss.font(&fonts.boring, &fonts.double, &fonts.double))[0])
 }
 
-// disparage is long for 'diss'.
-func disparage(esc *escape) {
-	rand.Seed(time.Now().UnixNano())
-	disses := [...]string{
-		"Below is a catalogue of your failures.",
-		"I'm not mad. I'm disappointed.",
-		"Crushing failure and despair.",
-		"Are you even trying?",
-		"Maybe you should take a break.",
-		"One should not fear failure. But oh, dear.",
-		"Once more unto the breach, dear friends, once more.",
-		"Aw, bless.",
-		"No, no, I'm laughing " + esc.em("with") + " you.",
+// disparage is long for 'diss'. The worse the run went, the harsher it gets.
+func disparage(w io.Writer, esc *escape, ss *summary) {
+	disses := [...][]string{
+		// a near miss
+		{
+			"Below is a catalogue of your failures.",
+			"Once more unto the breach, dear friends, once more.",
+			"Aw, bless.",
+			"No, no, I'm laughing " + esc.em("with") + " you.",
+		},
+		// meh
+		{
+			"I'm not mad. I'm disappointed.",
+			"Maybe you should take a break.",
+			"One should not fear failure. But oh, dear.",
+		},
+		// a disaster
+		{
+			"Crushing failure and despair.",
+			"Are you even trying?",
+		},
 	}
+	passed, counted := ss.tests.passed, ss.outOf()
+	tier := 1
+	switch {
+	case counted <= 0:
+		// nothing to go on but failure
+		passed, counted = 0, 1
+		tier = 2
+	case 2*ss.tests.failed > counted:
+		tier = 2
+	case ss.tests.failed <= 2 && ss.packages.errored == 0:
+		tier = 0
+	}
+	if passed < 0 {
+		passed = 0
+	}
+	diss := disses[tier][rand.Intn(len(disses[tier]))]
 
-	fmt.Print("\n", disses[rand.Intn(len(disses))], "\n\n")
+	fmt.Fprint(w, "\n", esc.rgb(ss.colour(passed, counted)), diss, esc.endc, "\n\n")
 }
 
 func common(a, b string) string {
@@ -444,117 +1222,746 @@ func common(a, b string) string {
 	return a[:last]
 }
 
-func mkContext() context.Context {
+// mkContext returns a context that's cancelled on SIGINT. On SIGQUIT
+// (where there is one), onQuit is called instead, and things carry on.
+func mkContext(onQuit func()) (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, os.Interrupt)
+	signal.Notify(ch, append([]os.Signal{os.Interrupt}, quitSignals...)...)
 	go func() {
-		select {
-		case <-ctx.Done():
-			signal.Stop(ch)
-			close(ch)
-		case sig := <-ch:
-			if sig == nil {
-				// channel was closed
+		for {
+			select {
+			case <-ctx.Done():
+				signal.Stop(ch)
+				close(ch)
+				return
+			case sig := <-ch:
+				if sig == nil {
+					// channel was closed
+					return
+				}
+				if sig != os.Interrupt {
+					onQuit()
+					continue
+				}
+				cancel()
 				return
 			}
-			cancel()
 		}
 	}()
-	return ctx
+	return ctx, cancel
 }
 
-var failRx = regexp.MustCompile(`^FAIL\s+(\S+)\s*.*`)
-
-func main() {
-	log.SetFlags(0)
-	ctx := mkContext()
-
-	var stream io.Reader
-	var progress progressReporter
-	var sums summary
-	escOverride := os.Getenv("GOCTEST_ESC")
-	prefix := unsetPrefix
-	compiled := ""
-
-	args := make([]string, 2, len(os.Args)+1)
-	args[0] = "test"
-	args[1] = "-json"
-loop:
-	for i := 1; i < len(os.Args); i++ {
-		arg := os.Args[i]
-		if idx := strings.IndexByte(arg, '='); idx > -1 {
-			v := arg[idx+1:]
-			switch arg[:idx] {
-			case "--esc":
-				escOverride = v
-			case "--trim":
-				prefix = v
-			case "-c":
-				compiled = v
-			default:
-				args = append(args, arg)
-			}
-		} else {
-			switch arg {
-			case "--":
-				args = append(args, os.Args[i+1:]...)
-				break loop
-			case "--esc":
-				i++
-				escOverride = os.Args[i]
-			case "--trim":
-				i++
-				prefix = os.Args[i]
-			case "-":
-				stream = os.Stdin
-			case "-q":
-				progress = &quietProgress{}
-			case "-v":
-				progress = &verboseProgress{seenFails: map[string]bool{}}
-			case "-c":
-				i++
-				compiled = os.Args[i]
-			case "-json":
-			case "-h", "-help", "--help":
-				fmt.Print(usage[1:])
-				return
-			default:
-				args = append(args, arg)
-			}
-		}
+// version returns a one-line description of what version of goctest
+// this is, from the build info embedded by the go tool.
+func version() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "goctest devel"
 	}
-	if progress == nil {
-		progress = &defaultProgress{}
+	v := bi.Main.Version
+	if v == "" || v == "(devel)" {
+		v = "devel"
 	}
-	esc := progress.setEscape(escOverride)
-
-	if prefix == unsetPrefix {
-		// don't give up hope
-		out, err := exec.CommandContext(ctx, "go", "list", "-m").Output()
-		if err == nil {
-			prefix = strings.TrimSpace(string(out))
+	rev, when, dirty := vcsInfo(bi)
+	if rev != "" {
+		if len(rev) > 12 {
+			rev = rev[:12]
 		}
+		if dirty {
+			rev += "+dirty"
+		}
+		v += " (" + rev
+		if when != "" {
+			v += ", " + when
+		}
+		v += ")"
 	}
+	return "goctest " + v + " built with " + runtime.Version()
+}
 
-	if compiled != "" && compiled != "-" {
-		if stream != nil {
-			log.Fatal("The flags ‘-c’ and ‘-’ are mutualy exclusive (did you mean ‘-c -’?)")
+// a failedTest is what ‘--fails-jsonl’ writes out for each failed test.
+type failedTest struct {
+	Package string  `json:"package"`
+	Test    string  `json:"test"`
+	Output  string  `json:"output"`
+	Elapsed float64 `json:"elapsed"`
+	Label   string  `json:"label,omitempty"`
+}
+
+func writeFailsJSONL(path string, failed []failedTest) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, ft := range failed {
+		if err := enc.Encode(ft); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func readFailsJSONL(path string) ([]failedTest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var failed []failedTest
+	dec := json.NewDecoder(f)
+	for {
+		var ft failedTest
+		if err := dec.Decode(&ft); err != nil {
+			if err == io.EOF {
+				return failed, nil
+			}
+			return failed, err
+		}
+		failed = append(failed, ft)
+	}
+}
+
+// shellQuote joins the arguments into something you could paste into
+// a shell.
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n'\"\\$`|&;<>()*?[]#~{}!") {
+			arg = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// fontName returns the name of the font the reporter uses for a banner
+// of the given size.
+func fontName(p progressReporter, size string) string {
+	pick := func(small, normal, large string) string {
+		switch size {
+		case "small":
+			return small
+		case "large":
+			return large
+		}
+		return normal
+	}
+	switch p.(type) {
+	case *verboseProgress:
+		return pick("braille", "future", "future")
+	case *quietProgress, *tinyProgress:
+		return pick("boring", "double", "double")
+	case *documentProgress, *silentProgress:
+		return "(none)"
+	default:
+		return pick("double", "braille", "future")
+	}
+}
+
+// maxLineLength is the longest line goctest will read. Tests can and do
+// print very long lines (big diffs, base64 blobs, ...), so this is
+// a lot more than bufio's default.
+const maxLineLength = 256 << 20
+
+// newScanner returns a scanner that reads the test runner's output a
+// line at a time.
+func newScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	return scanner
+}
+
+// passThrough writes out the given line and whatever's left to scan as
+// go test would have without ‘-json’, or as is if it isn't JSON.
+func passThrough(w io.Writer, line []byte, scanner *bufio.Scanner) {
+	for {
+		var ev TestEvent
+		if len(line) > 0 && line[0] == '{' && json.Unmarshal(line, &ev) == nil {
+			fmt.Fprint(w, ev.Output)
+		} else if len(line) > 0 {
+			fmt.Fprintf(w, "%s\n", line)
+		}
+		if !scanner.Scan() {
+			return
+		}
+		line = scanner.Bytes()
+	}
+}
+
+// sniffJSON peeks at the reader to see whether what's coming looks
+// like JSON (or at least starts with a ‘{’).
+func sniffJSON(r *bufio.Reader) bool {
+	for n := 1; ; n++ {
+		b, _ := r.Peek(n)
+		if len(b) < n {
+			return false
+		}
+		switch b[n-1] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// cachedRx matches go test saying a package's results came from its cache
+var cachedRx = regexp.MustCompile(`^ok  \t.*\t\(cached\)`)
+
+var failRx = regexp.MustCompile(`^FAIL\s+(\S+)\s*.*`)
+
+func main() {
+	os.Exit(run())
+}
+
+// progressOut returns where the progress report goes when it's to be
+// shown after the summary: with reverse, it's only held back until then;
+// otherwise it's shown as it happens, and held on to as well to be shown
+// again once the screen's cleared and the summary's at the top.
+func progressOut(w io.Writer, held *bytes.Buffer, reverse bool) io.Writer {
+	if reverse {
+		return held
+	}
+	return io.MultiWriter(w, held)
+}
+
+// exitStatus works out what goctest should exit with: 1 if anything
+// failed (failures that were expected, or flaky, notwithstanding), or
+// whatever go test exited with if it failed without saying why.
+func exitStatus(ss *summary, goErr error) int {
+	if ss.tests.failed > 0 || ss.packages.errored > 0 {
+		return 1
+	}
+	if ss.packages.failed > ss.excused {
+		// packages that failed for something other than a flaky test,
+		// e.g. in TestMain
+		return 1
+	}
+	if goErr == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(goErr, &exitErr) || exitErr.ExitCode() < 1 {
+		return 1
+	}
+	if exitErr.ExitCode() == 1 && ss.packages.xfailed+ss.excused > 0 {
+		// go test failing the packages with expected failures, or
+		// flaky tests, is why it failed
+		return 0
+	}
+	return exitErr.ExitCode()
+}
+
+func run() (status int) {
+	log.SetFlags(0)
+	tracked := newRunning()
+	ctx, cancel := mkContext(func() { tracked.report(os.Stderr) })
+	defer cancel()
+
+	opts := defaultOptions()
+	opts.escOverride = os.Getenv("GOCTEST_ESC")
+	opts.parse(os.Args[1:])
+	if opts.help {
+		fmt.Print(usage[1:])
+		return 0
+	}
+	if opts.version {
+		fmt.Println(version())
+		return 0
+	}
+	opts.check()
+	if opts.watch {
+		return watch(ctx, opts)
+	}
+
+	var stream io.Reader
+	if opts.fromStdin {
+		stream = os.Stdin
+	}
+	var progress progressReporter
+	switch opts.verbosity {
+	case "quiet":
+		progress = &quietProgress{}
+	case "verbose":
+		progress = &verboseProgress{seenFails: map[string]bool{}}
+	}
+	sums := opts.summary()
+	prefix := opts.prefix
+	compiled := opts.compiled
+	since := opts.since
+	var flaked *flakes
+	if opts.flaky || opts.flakyFile != "" {
+		flaked = newFlakes()
+	}
+	var skipped *skipReasons
+	if opts.skipReasons {
+		skipped = newSkipReasons()
+	}
+	var failSkips *skipGate
+	if opts.failOnSkip {
+		failSkips = &skipGate{}
+	}
+	var pkgOut *pkgOutput
+	if opts.pkgOutput {
+		pkgOut = newPkgOutput()
+	}
+	var mute *muted
+	for _, spec := range opts.mutes {
+		mute = addMuted(mute, spec)
+	}
+	var builds *buildTimes
+	if opts.showBuildTime {
+		builds = newBuildTimes()
+	}
+	var shares *testShares
+	if opts.relativeTime {
+		shares = newTestShares()
+	}
+	var prof *profile
+	if opts.profile {
+		prof = newProfile()
+	}
+	var noticed *notices
+	if len(opts.noticeRxs) > 0 {
+		noticed = newNotices(opts.noticeRxs)
+	}
+	if opts.thousandsSet {
+		thousands = opts.thousands
+	}
+	budget := outputBudget{max: opts.maxOutputBytes}
+	var rerun *reruns
+	var rerunArgs []string
+	var failedPkgs []string
+	cachedPkgs := map[string]bool{}
+	var benches benchmarks
+	var failed []failedTest
+	var stdin io.Reader = os.Stdin
+	args := append([]string{"test", "-json"}, opts.goArgs...)
+	if opts.format != "" {
+		f, ok := formatters[opts.format]
+		if !ok {
+			log.Fatalf("Unknown format %q (known formats are ‘markdown’, ‘org’, and ‘asciidoc’).", opts.format)
+		}
+		progress = &documentProgress{format: f, output: map[string][]string{}, label: opts.label}
+	}
+	if opts.tiny {
+		progress = &tinyProgress{}
+	}
+	if opts.porcelain {
+		progress = &porcelainProgress{}
+	}
+	if opts.rerunOnly {
+		progress = &silentProgress{}
+	}
+	if progress == nil {
+		progress = &defaultProgress{}
+	}
+	switch p := progress.(type) {
+	case *verboseProgress:
+		p.timestamps = opts.timestamps
+		p.columns = opts.columns
+	case *defaultProgress:
+		p.collapse = opts.collapse
+	}
+	if opts.sortPkgs == "time" {
+		if p, ok := progress.(*defaultProgress); ok {
+			p.sortByTime = true
+		}
+	}
+	esc := progress.setEscape(opts.escOverride)
+	glyphs, ok := glyphSets[opts.glyphName]
+	if !ok {
+		log.Fatalf("Unknown glyph set %q (known sets are ‘default’, ‘ascii’, and ‘emoji’).", opts.glyphName)
+	}
+	progress.setLayout(termWidth(os.Stdout), glyphs)
+	reporters := []progressReporter{progress}
+	if opts.lazy {
+		reporters[0] = newLazyProgress(progress, esc)
+	}
+	// with --reverse, or --summary top, the progress report is shown
+	// again after the summary, which only makes sense on a terminal
+	var out io.Writer = os.Stdout
+	var held bytes.Buffer
+	holding := (opts.reverse || opts.summaryAt == "top") && isTerminal(os.Stdout)
+	if holding {
+		out = progressOut(os.Stdout, &held, opts.reverse)
+		reporters[0].setOutput(out)
+	}
+	var plain *os.File
+	if opts.alsoPlain != "" && !opts.dryRun {
+		var err error
+		plain, err = os.Create(opts.alsoPlain)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer plain.Close()
+		p := &defaultProgress{}
+		p.setEscape("bare")
+		p.setLayout(0, glyphs)
+		p.setOutput(plain)
+		reporters = append(reporters, p)
+	}
+	if esc.name == "test" {
+		opts.deterministic = true
+	}
+	if !opts.thousandsSet && !opts.deterministic {
+		thousands = localThousands()
+	}
+	if opts.deterministic {
+		rand.Seed(1)
+		if p, ok := progress.(*defaultProgress); ok {
+			p.sortByTime = false
+		}
+	} else {
+		rand.Seed(time.Now().UnixNano())
+	}
+	failEsc := esc
+	if opts.failEscOverride != "" {
+		failEsc = guessEscape(opts.failEscOverride)
+	}
+
+	if opts.merge {
+		_, files, _ := splitArgs(args[2:])
+		if len(files) == 0 {
+			log.Fatal("The flag ‘--merge’ needs the files written by ‘--summary-json’ to merge.")
+		}
+		if err := mergeSummaries(files, &sums); err != nil {
+			log.Fatal(err)
+		}
+		for _, p := range reporters {
+			p.summarize(&sums)
+		}
+		if opts.label != "" && opts.format == "" {
+			fmt.Printf("Label: %s\n", esc.em(opts.label))
+		}
+		if opts.summaryFile != "" {
+			if err := writeSummaryJSON(opts.summaryFile, &sums, opts.label); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return exitStatus(&sums, nil)
+	}
+
+	var filter *outputFilter
+	if opts.filterSpec != "" {
+		var err error
+		filter, err = newFilter(opts.filterSpec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if p, ok := progress.(*documentProgress); ok {
+			p.filter = func(output []string) []string { return filter.apply(ctx, output) }
+		}
+	}
+
+	trail := newBreadcrumbs(opts.contextTests)
+	leaked := newLeaks()
+	var times *timings
+	if opts.timingsFile != "" {
+		times = newTimings()
+	}
+	var stall *stallWatch
+	var slowPkgs *pkgDeadline
+	if opts.pkgLimit != "" {
+		limit, err := time.ParseDuration(opts.pkgLimit)
+		if err != nil {
+			log.Fatalf("The flag ‘--pkg-deadline’ needs a duration, e.g. 30s, not %q.", opts.pkgLimit)
+		}
+		slowPkgs = newPkgDeadline(limit)
+	}
+	if opts.stallAfter != "" {
+		after, err := time.ParseDuration(opts.stallAfter)
+		if err != nil {
+			log.Fatalf("The flag ‘--stall’ needs a duration, e.g. 60s, not %q.", opts.stallAfter)
+		}
+		switch opts.stallAction {
+		case "warn", "kill":
+		default:
+			log.Fatalf("The flag ‘--stall-action’ needs one of ‘warn’ or ‘kill’, not %q.", opts.stallAction)
+		}
+		stall = newStallWatch(after, opts.stallAction == "kill")
+	}
+	if pkgOut != nil {
+		pkgOut.max = opts.maxPkgOutput
+	}
+	if failSkips != nil {
+		failSkips.shortOK = opts.shortSkipsOK
+	}
+	var known *baseline
+	if opts.baselineFile != "" {
+		var err error
+		known, err = loadBaseline(opts.baselineFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var owned *owners
+	if opts.ownersFile != "" {
+		var err error
+		owned, err = loadOwners(opts.ownersFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if opts.noTrim {
+		prefix = ""
+	}
+	if opts.sniff {
+		if stream != nil || compiled != "" {
+			log.Fatal("The flag ‘--stdin’ can't be used with ‘-’ nor ‘-c’.")
+		}
+		r := bufio.NewReader(os.Stdin)
+		if sniffJSON(r) {
+			stream = r
+		} else {
+			compiled = "-"
+			stdin = r
+		}
+	}
+	if opts.gobFile != "" {
+		if stream != nil || compiled != "" {
+			log.Fatal("The flag ‘--gob’ can't be used with ‘-’, ‘-c’, nor ‘--stdin’.")
+		}
+		var err error
+		stream, err = readGob(opts.gobFile)
+		if err != nil {
+			log.Fatalf("Unable to read %q: %v", opts.gobFile, err)
+		}
+	}
+	if opts.demo {
+		if stream != nil || compiled != "" {
+			log.Fatal("The flag ‘--demo’ can't be used with ‘-’, ‘-c’, ‘--stdin’, nor ‘--gob’.")
 		}
-		compiled, err := exec.LookPath(compiled)
+		var err error
+		stream, err = asJSON(demoEvents())
 		if err != nil {
 			log.Fatal(err)
 		}
-		x := make([]string, len(args)+2)
-		copy(x, []string{"tool", "test2json", compiled, "-test.v"})
-		copy(x[4:], args[2:])
-		args = x
+		if prefix == unsetPrefix {
+			prefix = demoPrefix
+		}
+	}
+	if prefix == unsetPrefix && stream == nil && compiled != "-" {
+		// don't give up hope
+		if mod, ok := modulePrefix(ctx); ok {
+			prefix = mod
+		}
+	}
+
+	if compiled == "" {
+		// can't say how to rerun a test binary's tests without knowing
+		// what package it came from (and with ‘-c -’ it's anybody's guess)
+		rerun = newReruns()
+		if compiled == "" && stream == nil {
+			rerunArgs = rerunFlags(args[2:])
+		}
+	}
+	var goFlags []string
+	if compiled == "" && stream == nil {
+		goFlags, _, _ = splitArgs(args[2:])
+	}
+
+	if opts.onlyChangedTests && compiled == "" && stream == nil {
+		flags, patterns, rest := splitArgs(args[2:])
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		ref := since
+		if ref == "" {
+			ref = "HEAD"
+		}
+		pkgs, tests, err := changedTests(ctx, ref, patterns)
+		switch {
+		case err != nil:
+			log.Printf("Unable to tell which tests changed since %s (%v); going by package.", ref, err)
+			since = ref
+		case len(tests) == 0:
+			log.Printf("No tests changed since %s that match; running everything.", ref)
+			since = ""
+		default:
+			if _, ok := flagValue(flags, "run"); ok {
+				log.Print("The flag ‘--only-changed-tests’ overrides the ‘-run’ given to go test.")
+			}
+			x := append([]string{"test", "-json"}, flags...)
+			x = append(x, "-run", "^("+strings.Join(tests, "|")+")$")
+			x = append(x, pkgs...)
+			args = append(x, rest...)
+			since = ""
+		}
 	}
+	if since != "" && compiled == "" && stream == nil {
+		flags, patterns, rest := splitArgs(args[2:])
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		pkgs, err := changedPackages(ctx, since, patterns)
+		switch {
+		case err != nil:
+			log.Printf("Unable to work out what changed since %s (%v); running everything.", since, err)
+		case len(pkgs) == 0:
+			log.Printf("Nothing changed since %s that matches; running everything.", since)
+		default:
+			x := append([]string{"test", "-json"}, flags...)
+			x = append(x, pkgs...)
+			args = append(x, rest...)
+		}
+	}
+	if opts.shard != "" && compiled == "" && stream == nil {
+		m, n, err := parseShard(opts.shard)
+		if err != nil {
+			log.Fatalf("The flag ‘--shard’ needs to be like 2/4, not %q (%v).", opts.shard, err)
+		}
+		var took map[string]float64
+		if opts.shardTimings != "" {
+			took, err = loadTimings(opts.shardTimings)
+			if err != nil {
+				log.Fatalf("Unable to read timings from %q: %v", opts.shardTimings, err)
+			}
+		}
+		flags, patterns, rest := splitArgs(args[2:])
+		if len(patterns) == 0 {
+			patterns = []string{"."}
+		}
+		dirs, err := packageDirs(ctx, patterns)
+		if err != nil {
+			log.Fatalf("Unable to list the packages to split into shards: %v", err)
+		}
+		all := make([]string, 0, len(dirs))
+		for _, pkg := range dirs {
+			all = append(all, pkg)
+		}
+		pkgs := shardOf(all, m, n, took)
+		if len(pkgs) == 0 {
+			log.Printf("Shard %s has no packages to test.", opts.shard)
+			return 0
+		}
+		x := append([]string{"test", "-json"}, flags...)
+		x = append(x, pkgs...)
+		args = append(x, rest...)
+	}
+
+	var binChain *chain
+	if compiled != "" && compiled != "-" {
+		if stream != nil {
+			log.Fatal("The flags ‘-c’ and ‘-’ are mutualy exclusive (did you mean ‘-c -’?)")
+		}
+		if len(opts.binaries) > 1 {
+			// run them one after the other, telling them apart by name
+			binChain = &chain{}
+			for _, bin := range opts.binaries {
+				if bin == "-" {
+					log.Fatal("The flag ‘-c -’ can't be used with other ‘-c’ flags.")
+				}
+				path, err := exec.LookPath(bin)
+				if err != nil {
+					log.Fatal(err)
+				}
+				name := strings.TrimSuffix(filepath.Base(bin), ".test")
+				x := append([]string{"tool", "test2json", "-p", name, path, "-test.v"}, args[2:]...)
+				cmd := exec.CommandContext(ctx, "go", x...)
+				cmd.Stderr = os.Stderr
+				binChain.add(name, cmd)
+			}
+		} else {
+			compiled, err := exec.LookPath(compiled)
+			if err != nil {
+				log.Fatal(err)
+			}
+			x := make([]string, len(args)+2)
+			copy(x, []string{"tool", "test2json", compiled, "-test.v"})
+			copy(x[4:], args[2:])
+			args = x
+		}
+	}
+	if opts.dryRun {
+		switch {
+		case binChain != nil:
+			for _, cmd := range binChain.cmds {
+				fmt.Fprintln(os.Stderr, "would run:", shellQuote(cmd.Args))
+			}
+		case compiled == "-":
+			fmt.Fprintln(os.Stderr, "would run: go tool test2json < stdin")
+		case opts.gobFile != "":
+			fmt.Fprintln(os.Stderr, "would read events from", opts.gobFile)
+		case opts.demo:
+			fmt.Fprintln(os.Stderr, "would make up events for a demo")
+		case stream != nil:
+			fmt.Fprintln(os.Stderr, "would read JSON from stdin")
+		default:
+			fmt.Fprintln(os.Stderr, "would run:", shellQuote(append([]string{"go"}, args...)))
+		}
+		if prefix == unsetPrefix {
+			fmt.Fprintln(os.Stderr, "trim: (guess as we go)")
+		} else {
+			fmt.Fprintf(os.Stderr, "trim: %q\n", prefix)
+		}
+		fmt.Fprintf(os.Stderr, "escapes: %s (failures: %s)\n", esc.name, failEsc.name)
+		fmt.Fprintln(os.Stderr, "font:", fontName(progress, sums.size))
+		return 0
+	}
+	var clk *clock
+	wantClock := !opts.rerunOnly && !opts.tiny && !opts.porcelain && !opts.deterministic && isTerminal(os.Stdout)
+	if binChain != nil {
+		if opts.echoCommand {
+			for _, cmd := range binChain.cmds {
+				fmt.Fprintln(os.Stderr, shellQuote(cmd.Args))
+			}
+		}
+		stream = binChain
+		if wantClock {
+			clk = startClock(ctx, esc, opts.animate)
+		}
+	}
+	goStderr := &labelled{w: os.Stderr, label: "[go test] "}
+	defer goStderr.flush()
+	if opts.keepGoing && compiled == "" && stream == nil {
+		flags, patterns, rest := splitArgs(args[2:])
+		mods, err := workspaceModules(ctx)
+		switch {
+		case err != nil:
+			log.Printf("Unable to list the workspace's modules (%v); running as usual.", err)
+		case len(mods) == 0:
+			// not in a workspace
+		case len(patterns) > 1 || len(patterns) == 1 && patterns[0] != "./...":
+			log.Print("The flag ‘--keep-going’ only works on whole modules (i.e. ‘./...’); running as usual.")
+		default:
+			c := &chain{merge: !opts.separateStderr}
+			for _, mod := range mods {
+				x := append([]string{"test", "-json"}, flags...)
+				x = append(x, "./...")
+				cmd := exec.CommandContext(ctx, "go", append(x, rest...)...)
+				cmd.Dir = mod.dir
+				if opts.separateStderr {
+					cmd.Stderr = goStderr
+				}
+				if opts.echoCommand {
+					fmt.Fprintln(os.Stderr, "(cd", shellQuote([]string{mod.dir}), "&&", shellQuote(cmd.Args)+")")
+				}
+				c.add(mod.path, cmd)
+			}
+			stream = c
+			if wantClock {
+				clk = startClock(ctx, esc, opts.animate)
+			}
+		}
+	}
+	var goCmd *exec.Cmd
 	if stream == nil {
 		var cmd *exec.Cmd
 		if compiled == "-" {
 			cmd = exec.CommandContext(ctx, "go", "tool", "test2json")
-			cmd.Stdin = os.Stdin
+			if opts.stripANSI {
+				// test2json would keep all but the escape character
+				stdin = stripEscapes(stdin)
+			}
+			cmd.Stdin = stdin
 		} else {
 			cmd = exec.CommandContext(ctx, "go", args...)
 		}
@@ -564,96 +1971,498 @@ loop:
 			log.Fatal(err)
 		}
 		if compiled == "" {
-			cmd.Stderr = cmd.Stdout
+			if opts.separateStderr {
+				cmd.Stderr = goStderr
+			} else {
+				cmd.Stderr = cmd.Stdout
+			}
+		}
+		if opts.echoCommand {
+			if compiled == "-" {
+				fmt.Fprintln(os.Stderr, "go tool test2json < stdin")
+			} else {
+				fmt.Fprintln(os.Stderr, shellQuote(cmd.Args))
+			}
 		}
 		err = cmd.Start()
+		if errors.Is(err, exec.ErrNotFound) {
+			log.Fatal("Couldn't find ‘go’. Is Go installed, and in your $PATH? See https://go.dev/doc/install")
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
+		goCmd = cmd
 		stream = pipe
+		if compiled != "-" && wantClock {
+			clk = startClock(ctx, esc, opts.animate)
+		}
 	}
 
 	var fails []string
-	inProgress := map[string][]string{}
+	// what the test runner said that wasn't test output, to make sense
+	// of it failing
+	var said []string
+	aborted := false
+	inProgress := map[string]*heldOutput{}
+	// how many lines of package output were dropped for going over
+	// ‘--max-package-output’
+	pkgDropped := 0
+	hold := func(name, output string) {
+		h := inProgress[name]
+		if name == errorPlaceholder && opts.maxPkgOutput > 0 && h.len() >= opts.maxPkgOutput {
+			pkgDropped++
+			return
+		}
+		if h == nil {
+			h = newHeldOutput()
+			inProgress[name] = h
+		}
+		h.add(output)
+		budget.hold(output)
+		if budget.over() {
+			fails = budget.trim(fails)
+			h.set(budget.trim(h.lines()))
+		}
+	}
 	// if it weren't for those pesky non-JSON lines, we could just
 	//     dec := json.NewDecoder(stream)
 	//     for dec.More() { ...
 	// TODO: file a bug with Go about the non-JSON lines in JSON output
-	scanner := bufio.NewScanner(stream)
-	for scanner.Scan() {
-		var ev TestEvent
-		line := scanner.Bytes()
+	scanner := newScanner(stream)
+	var ev, last TestEvent
+	var line []byte
+	// should goctest trip up while reading the stream, what's left of it
+	// is passed through as it comes, so it's never worse than go test;
+	// the clock is left locked, so it stays out of the way
+	trippedUp := func(why interface{}) int {
+		log.Printf("goctest tripped up (%v); passing go test's output through as it comes.", why)
+		passThrough(os.Stdout, line, scanner)
+		if goCmd != nil {
+			goCmd.Wait()
+		}
+		return 1
+	}
+	scanning := true
+	defer func() {
+		if !scanning {
+			return
+		}
+		if r := recover(); r != nil {
+			status = trippedUp(r)
+		}
+	}()
+	compat := newCompat()
+	builds.begin()
+	stall.start(ctx, cancel, clk)
+	for prof.worked(); scanner.Scan(); prof.worked() {
+		prof.waited()
+		stall.poke()
+		line = scanner.Bytes()
 		if len(line) == 0 {
 			continue
 		}
+		clk.Lock()
+		ev = TestEvent{}
 		if line[0] == '{' {
-			err := json.Unmarshal(line, &ev)
-			if err != nil {
-				log.Fatal(err)
+			if err := json.Unmarshal(line, &ev); err != nil {
+				scanning = false
+				return trippedUp(err)
+			}
+			if opts.stripANSI {
+				ev.Output = escRx.ReplaceAllString(ev.Output, "")
+			}
+			if mute.mutes(&ev) {
+				clk.Unlock()
+				continue
+			}
+			if ev.Action == "build-output" {
+				if !opts.noBuildOutput {
+					fmt.Fprint(os.Stderr, ev.Output)
+				}
+				if len(said) < 100 {
+					said = append(said, ev.Output)
+				}
+			}
+			if !compat.normalize(&ev) {
+				clk.Unlock()
+				continue
 			}
 		} else {
+			if opts.stripANSI {
+				line = escRx.ReplaceAll(line, nil)
+			}
+			ev.Output = string(line) + "\n"
+			ev.Test = errorPlaceholder
 			if m := failRx.FindSubmatch(line); m != nil {
 				// fake it
-				ev = TestEvent{
-					Action:  "error",
-					Package: string(m[1]),
-					Output:  string(line) + "\n",
-					Test:    errorPlaceholder,
+				ev.Action = "error"
+				ev.Package = string(m[1])
+				if mute.mutes(&ev) {
+					clk.Unlock()
+					continue
 				}
+			} else if opts.strictJSON {
+				log.Fatalf("Found a line that isn't JSON in the stream: %q", line)
 			} else {
-				ev = TestEvent{
-					Action: "output",
-					Output: string(line) + "\n",
-					Test:   errorPlaceholder,
-				}
+				ev.Action = "output"
+			}
+			if !opts.noBuildOutput {
+				fmt.Fprint(os.Stderr, ev.Output)
+			}
+			if len(said) < 100 {
+				said = append(said, ev.Output)
 			}
-			fmt.Fprintln(os.Stderr, string(line))
 		}
-		if prefix == unsetPrefix {
+		if ev.isResult() && ev.sameAs(&last) {
+			// the same result twice in a row, e.g. from go test
+			// being told -v one time too many
+			clk.Unlock()
+			continue
+		}
+		last = ev
+		switch {
+		case opts.noTrim, ev.Package == "":
+			// leave it be
+		case prefix == unsetPrefix:
 			// take a wild guess
 			prefix = ev.Package
-		} else if !strings.HasPrefix(ev.Package, prefix) {
+		case !strings.HasPrefix(ev.Package, prefix):
 			// adjust that guess
 			prefix = common(prefix, ev.Package)
 		}
 		ev.prefix = prefix
+		tracked.track(&ev)
+		if ev.Test == "" {
+			switch {
+			case ev.Action == "output" && cachedRx.MatchString(ev.Output):
+				cachedPkgs[ev.Package] = true
+			case ev.Action == "pass" && cachedPkgs[ev.Package]:
+				ev.cached = true
+				fallthrough
+			case ev.isResult():
+				delete(cachedPkgs, ev.Package)
+			}
+		}
+		known.adjust(&ev)
+		flaked.check(&ev, &sums)
 
-		progress.report(&ev)
+		// with --leaves-only, tests that are only there to group their
+		// subtests go unreported; with --quiet-skips, skips do; and with
+		// --hide-empty, packages without tests (which go says skipped)
+		hidden := (opts.leavesOnly && ev.isResult() && sums.isParent(&ev)) ||
+			(opts.quietSkips && ev.Action == "skip") ||
+			(opts.hideEmpty && ev.Action == "skip" && !ev.isTest())
+		if !hidden && (!opts.quietErrors || ev.Action != "error") {
+			for _, p := range reporters {
+				p.report(&ev)
+			}
+		}
 		sums.add(&ev)
+		times.add(&ev, &sums)
+		benches.parse(&ev)
+		builds.add(&ev)
+		shares.add(&ev)
+		slowPkgs.add(&ev)
+		rerun.add(&ev)
+		pkgOut.add(&ev)
+		leaked.add(&ev)
+		if owned != nil && !ev.isTest() && (ev.Action == "fail" || ev.Action == "error") {
+			failedPkgs = append(failedPkgs, ev.Package)
+		}
 
 		if ev.Test != "" {
 			name := ev.name()
 			switch ev.Action {
 			default:
 				if ev.Output != "" {
-					inProgress[name] = append(inProgress[name], ev.Output)
+					hold(name, ev.Output)
 				}
+			case "pause", "cont":
+				// a parallel test waiting for its turn, and getting
+				// it; the "=== PAUSE" and "=== CONT" lines come as
+				// output of their own
 			case "error":
 				name = errorPlaceholder
-				if ev.Output != "" {
-					inProgress[name] = append(inProgress[name], ev.Output)
+				// a build's output comes all in the one event
+				for _, line := range strings.SplitAfter(ev.Output, "\n") {
+					if line != "" {
+						hold(name, line)
+					}
+				}
+				if pkgDropped > 0 {
+					marker := fmt.Sprintf("[%s of package output dropped]\n", gn("line", "lines")(pkgDropped))
+					if inProgress[name] == nil {
+						inProgress[name] = newHeldOutput()
+					}
+					inProgress[name].add(marker)
+					budget.hold(marker)
+					pkgDropped = 0
 				}
 				fallthrough
 			case "fail":
+				// what's held of the output, as it came
+				held := inProgress[name].lines()
+				inProgress[name].free()
+				delete(inProgress, name)
+				if name == errorPlaceholder {
+					// said by the package, outside of any test
+					leaked.check(ev.pkg(), held)
+				} else {
+					leaked.check(name, held)
+				}
+				output := held
+				if opts.prettyPanics {
+					output = collapsePanics(output, ev.prefix)
+				}
+				if opts.prettyLeaks {
+					output = collapseLeaks(output)
+				}
+				output = filter.apply(ctx, output)
+				shown := output
+				if opts.testify && ev.isTest() {
+					shown = collapseTestify(shown, failEsc)
+				}
+				if opts.diffHighlight && ev.isTest() {
+					shown = highlightDiffs(shown, failEsc)
+				}
+				if opts.artifactPrefix != "" && ev.isTest() {
+					if paths := artifacts(opts.artifactPrefix, output); len(paths) > 0 {
+						shown = append(shown[:len(shown):len(shown)], artifactsLine(paths, failEsc))
+					}
+				}
+				if crumbs := trail.before(&ev, failEsc); len(crumbs) > 0 {
+					shown = append(crumbs, shown...)
+				}
 				// XXX: put this behind a flag
-				for _, ev := range inProgress[name] {
-					fmt.Print(ev)
+				if opts.format == "" && !opts.rerunOnly && !opts.tiny && !opts.porcelain && !opts.lazy && !(opts.noBuildOutput && ev.Action == "error") {
+					for _, ev := range shown {
+						fmt.Fprint(out, ev)
+					}
 				}
-				fails = append(fails, inProgress[name]...)
-				fallthrough
-			case "pass", "skip":
+				if opts.quietErrors && ev.Action == "error" {
+					budget.release(held)
+				} else {
+					fails = budget.keep(fails, held, shown)
+				}
+				if opts.failsJSONL != "" && ev.isTest() {
+					failed = append(failed, failedTest{
+						Package: ev.Package,
+						Test:    ev.Test,
+						Output:  strings.Join(output, ""),
+						Elapsed: ev.Elapsed,
+						Label:   opts.label,
+					})
+				}
+			case "pass", "skip", "xfail", "xpass", "flaky":
+				h := inProgress[name]
+				if ev.Action == "pass" && ev.isTest() && noticed != nil {
+					noticed.add(name, h.lines())
+				}
+				if ev.Action == "skip" && ev.isTest() && (skipped != nil || failSkips != nil) {
+					lines := h.lines()
+					skipped.add(lines)
+					failSkips.add(name, lines)
+				}
+				budget.forget(h.size())
+				h.free()
 				delete(inProgress, name)
 			}
 		}
+		trail.add(&ev)
+		clk.Unlock()
+		if opts.maxFails > 0 && sums.tests.failed >= opts.maxFails {
+			aborted = true
+			cancel()
+			break
+		}
+	}
+	scanning = false
+	clk.stop()
+	if holding {
+		reporters[0].setOutput(os.Stdout)
 	}
 	if err := scanner.Err(); err != nil {
 		log.Fatal(err)
 	}
-	progress.summarize(&sums)
-	if len(fails) > 0 {
-		disparage(esc)
+	var goErr error
+	if goCmd != nil {
+		goErr = goCmd.Wait()
+	}
+	// all of go's stderr is in by now (with --keep-going, each command
+	// was waited for as the stream got to its end)
+	goStderr.flush()
+	if goCmd != nil && goErr != nil && !aborted && !stall.stopped() && sums.tests.isZero() {
+		// with --separate-stderr, what go said is there and not in the stream
+		log.Print(whyFailed(goErr, append(said, goStderr.lines()...)))
+	}
+	status = exitStatus(&sums, goErr)
+	if status == 0 && failSkips.failed() {
+		status = 1
+	}
+	if status == 0 && opts.strictExit && sums.tests.isZero() {
+		log.Print("No tests were run; did you mean to match something?")
+		status = 1
+	}
+	if opts.failsJSONL != "" {
+		if err := writeFailsJSONL(opts.failsJSONL, failed); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.summaryFile != "" {
+		if err := writeSummaryJSON(opts.summaryFile, &sums, opts.label); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.timingsFile != "" {
+		if err := times.write(opts.timingsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.flakyFile != "" {
+		if err := flaked.quarantine(opts.flakyFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.rerunOnly || opts.tiny || opts.porcelain {
+		for _, p := range reporters {
+			p.summarize(&sums)
+		}
+		if opts.rerunOnly {
+			for _, cmd := range rerun.commands(rerunArgs) {
+				fmt.Println(cmd)
+			}
+		}
+		prof.report(os.Stderr)
+		return status
+	}
+	if opts.deterministic {
+		// whatever order things happened in, tell it the same way
+		sort.Strings(failedPkgs)
+		if flaked != nil {
+			sort.Strings(flaked.names)
+		}
+		if known != nil {
+			sort.Strings(known.xpassed)
+		}
+		if skipped != nil {
+			sort.Strings(skipped.reasons)
+		}
+		if failSkips != nil {
+			sort.Strings(failSkips.names)
+		}
+		if noticed != nil {
+			sort.Strings(noticed.names)
+		}
+		sort.Strings(leaked.names)
+		if slowPkgs != nil {
+			sort.Strings(slowPkgs.pkgs)
+		}
+		if pkgOut != nil {
+			sort.Strings(pkgOut.pkgs)
+		}
+		if rerun != nil {
+			sort.Strings(rerun.pkgs)
+		}
+	}
+	if opts.summaryAt == "top" && isTerminal(os.Stdout) {
+		fmt.Print(esc.clear)
+	}
+	if opts.quietErrors && sums.packages.errored > 0 {
+		fmt.Printf("%s%s failed to build (run without ‘--quiet-errors’ to see why).%s\n",
+			esc.fail, gn("package", "packages")(sums.packages.errored), esc.endc)
+	}
+	for _, p := range reporters {
+		p.summarize(&sums)
+	}
+	if opts.label != "" && opts.format == "" {
+		fmt.Printf("Label: %s\n", esc.em(opts.label))
+	}
+	if flaked != nil && len(flaked.names) > 0 {
+		fmt.Println("\nThese tests were flaky within this run:")
+		for _, name := range flaked.names {
+			fmt.Println(" ", name)
+		}
+	}
+	if par := sums.parallelism(); par > 0 && !opts.deterministic {
+		fmt.Printf("Effective parallelism: %.1f×\n", par)
+	}
+	if !opts.deterministic {
+		for _, hint := range serialHints(goFlags, &sums) {
+			fmt.Println(esc.em("Hint:"), hint)
+		}
+	}
+	mute.report(os.Stdout, esc)
+	skipped.report(os.Stdout, esc)
+	failSkips.report(os.Stdout, esc)
+	pkgOut.report(os.Stdout)
+	leaked.report(os.Stdout, esc)
+	noticed.report(os.Stdout, esc)
+	if aborted {
+		fmt.Printf("%sAborted after %s.%s\n", esc.fail, gn("failure", "failures")(opts.maxFails), esc.endc)
+	}
+	slowPkgs.report(os.Stdout, esc)
+	if stall.stopped() {
+		fmt.Printf("%sStopped after no test activity for %s.%s\n", esc.fail, opts.stallAfter, esc.endc)
+	}
+	benches.report(os.Stdout, esc)
+	builds.report(os.Stdout)
+	shares.report(os.Stdout, esc)
+	owned.report(os.Stdout, failedPkgs)
+	if known != nil && len(known.xpassed) > 0 {
+		fmt.Println("\nThese tests from the baseline are now passing:")
+		for _, name := range known.xpassed {
+			fmt.Println(" ", name)
+		}
+	}
+	if held.Len() > 0 {
+		fmt.Println()
+		held.WriteTo(os.Stdout)
+	}
+	if len(fails) > 0 && opts.format == "" {
+		var out io.Writer = os.Stdout
+		var pg *pager
+		if opts.usePager {
+			pg = startPager()
+			if pg != nil {
+				out = pg
+			}
+		}
+		disparage(out, failEsc, &sums)
+		width := 0
+		if opts.wrapLines {
+			width = termWidth(os.Stdout)
+		}
 		for _, ev := range fails {
-			fmt.Print(ev)
+			fmt.Fprint(out, wrap(opts.marked.apply(ev, failEsc), width))
+		}
+		if pg != nil {
+			pg.Close()
+		}
+		if plain != nil {
+			disparage(plain, escapes[bareEsc], &sums)
+			for _, ev := range fails {
+				fmt.Fprint(plain, ev)
+			}
+		}
+		if cmds := rerun.commands(rerunArgs); len(cmds) > 0 {
+			fmt.Println("\nTo run just the failed tests again:")
+			for _, cmd := range cmds {
+				fmt.Println(" ", cmd)
+			}
+		}
+		if opts.summaryAt == "both" {
+			fmt.Print("\n", sums.compact(esc), "\n")
+		}
+	}
+	if opts.wantEnv {
+		gatherEnvInfo(ctx).report(os.Stdout)
+	}
+	if hints := profileHints(goFlags); len(hints) > 0 {
+		fmt.Println()
+		for _, hint := range hints {
+			fmt.Println(hint)
 		}
 	}
+	prof.report(os.Stderr)
+	return status
 }
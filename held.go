@@ -0,0 +1,89 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledOutput is the most output a heldOutput can have had in it and
+// still go back in the pool; the odd test that says a lot shouldn't keep
+// that much memory around for the ones after it.
+const maxPooledOutput = 64 << 10
+
+// heldPool has the heldOutputs of tests that are done, for reuse, so
+// that the many tests that pass don't each need new ones.
+var heldPool = sync.Pool{
+	New: func() interface{} { return new(heldOutput) },
+}
+
+// a heldOutput is what a test has said so far, line by line. Most tests
+// pass, and then all that's needed of it is how big it was, so the lines
+// are only made into strings if asked for. A nil heldOutput is empty.
+type heldOutput struct {
+	buf bytes.Buffer
+	// ends are where each line ends in buf
+	ends []int
+}
+
+func newHeldOutput() *heldOutput {
+	return heldPool.Get().(*heldOutput)
+}
+
+func (h *heldOutput) add(line string) {
+	h.buf.WriteString(line)
+	h.ends = append(h.ends, h.buf.Len())
+}
+
+// len returns how many lines there are.
+func (h *heldOutput) len() int {
+	if h == nil {
+		return 0
+	}
+	return len(h.ends)
+}
+
+// size returns how many bytes the lines add up to.
+func (h *heldOutput) size() int {
+	if h == nil {
+		return 0
+	}
+	return h.buf.Len()
+}
+
+// lines returns the lines, as strings of their own.
+func (h *heldOutput) lines() []string {
+	if h == nil || len(h.ends) == 0 {
+		return nil
+	}
+	b := h.buf.Bytes()
+	lines := make([]string, len(h.ends))
+	start := 0
+	for i, end := range h.ends {
+		lines[i] = string(b[start:end])
+		start = end
+	}
+	return lines
+}
+
+// set replaces the lines with the given ones.
+func (h *heldOutput) set(lines []string) {
+	h.buf.Reset()
+	h.ends = h.ends[:0]
+	for _, line := range lines {
+		h.add(line)
+	}
+}
+
+// free puts the heldOutput back in the pool; it's not to be used after.
+func (h *heldOutput) free() {
+	if h == nil || h.buf.Cap() > maxPooledOutput {
+		return
+	}
+	h.buf.Reset()
+	h.ends = h.ends[:0]
+	heldPool.Put(h)
+}
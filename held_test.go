@@ -0,0 +1,68 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeldOutput(t *testing.T) {
+	var h *heldOutput
+	if h.len() != 0 || h.size() != 0 || h.lines() != nil {
+		t.Fatal("expected a nil heldOutput to be empty")
+	}
+	h = newHeldOutput()
+	lines := []string{"=== RUN   TestA\n", "    a_test.go:7: hi\n", "--- PASS: TestA (0.00s)\n"}
+	for _, line := range lines {
+		h.add(line)
+	}
+	if size := len(lines[0]) + len(lines[1]) + len(lines[2]); h.len() != 3 || h.size() != size {
+		t.Errorf("expected 3 lines (%d bytes), got %d (%d bytes)", size, h.len(), h.size())
+	}
+	if got := h.lines(); !reflect.DeepEqual(got, lines) {
+		t.Errorf("expected %q, got %q", lines, got)
+	}
+	h.set(lines[1:])
+	if got := h.lines(); !reflect.DeepEqual(got, lines[1:]) {
+		t.Errorf("expected %q, got %q", lines[1:], got)
+	}
+	h.free()
+	if h = newHeldOutput(); h.len() != 0 {
+		t.Errorf("expected a new heldOutput to be empty, got %q", h.lines())
+	}
+}
+
+var benchLines = []string{
+	"=== RUN   TestSomething\n",
+	"    something_test.go:42: setting things up\n",
+	"    something_test.go:57: doing the thing\n",
+	"--- PASS: TestSomething (0.01s)\n",
+}
+
+// what holding on to the output of a test that passes costs, as it's
+// done for most tests
+func BenchmarkHeldOutput(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h := newHeldOutput()
+		for _, line := range benchLines {
+			h.add(line)
+		}
+		h.free()
+	}
+}
+
+// the same, as it was done before heldOutput
+func BenchmarkHeldSlice(b *testing.B) {
+	b.ReportAllocs()
+	inProgress := map[string][]string{}
+	for i := 0; i < b.N; i++ {
+		for _, line := range benchLines {
+			inProgress["TestSomething"] = append(inProgress["TestSomething"], line)
+		}
+		delete(inProgress, "TestSomething")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"regexp"
+	"sort"
+)
+
+// highlights are the regexps whose matches are made to stand out in
+// the catalogue of failures, each its own way.
+type highlights []*regexp.Regexp
+
+// apply marks the matches in the line. Where matches overlap the first
+// highlight wins, and escape sequences already in the line are left be.
+func (hs highlights) apply(line string, esc *escape) string {
+	if len(hs) == 0 {
+		return line
+	}
+	type span struct{ start, end, n int }
+	taken := func(spans []span, start, end int) bool {
+		for _, s := range spans {
+			if start < s.end && s.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	var escs []span
+	for _, loc := range escRx.FindAllStringIndex(line, -1) {
+		escs = append(escs, span{loc[0], loc[1], -1})
+	}
+	var spans []span
+	for n, rx := range hs {
+		for _, loc := range rx.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] || taken(escs, loc[0], loc[1]) || taken(spans, loc[0], loc[1]) {
+				continue
+			}
+			spans = append(spans, span{loc[0], loc[1], n})
+		}
+	}
+	if len(spans) == 0 {
+		return line
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	out := make([]byte, 0, len(line)+16*len(spans))
+	last := 0
+	for _, s := range spans {
+		out = append(out, line[last:s.start]...)
+		out = append(out, esc.mark(s.n, line[s.start:s.end])...)
+		last = s.end
+	}
+	out = append(out, line[last:]...)
+	return string(out)
+}
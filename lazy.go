@@ -0,0 +1,65 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// lazyProgress holds on to everything until the end. If nothing failed
+// it then says so in a single line; otherwise it has the reporter it
+// wraps show the packages that failed, as if they'd just happened.
+type lazyProgress struct {
+	progressReporter
+	esc    *escape
+	held   map[string][]TestEvent
+	pkgs   []string
+	failed map[string]bool
+}
+
+func newLazyProgress(p progressReporter, esc *escape) *lazyProgress {
+	return &lazyProgress{
+		progressReporter: p,
+		esc:              esc,
+		held:             map[string][]TestEvent{},
+		failed:           map[string]bool{},
+	}
+}
+
+func (p *lazyProgress) report(ev *TestEvent) {
+	if _, ok := p.held[ev.Package]; !ok {
+		p.pkgs = append(p.pkgs, ev.Package)
+	}
+	p.held[ev.Package] = append(p.held[ev.Package], *ev)
+	if ev.Action == "fail" || ev.Action == "error" {
+		p.failed[ev.Package] = true
+	}
+}
+
+func (p *lazyProgress) summarize(ss *summary) {
+	if len(p.failed) == 0 {
+		fmt.Fprintln(p.out(), ss.compact(p.esc))
+		return
+	}
+	for _, pkg := range p.pkgs {
+		if !p.failed[pkg] {
+			continue
+		}
+		for i := range p.held[pkg] {
+			p.progressReporter.report(&p.held[pkg][i])
+		}
+	}
+	p.progressReporter.summarize(ss)
+}
+
+// out is where the wrapped reporter writes.
+func (p *lazyProgress) out() io.Writer {
+	if w, ok := p.progressReporter.(interface{ writer() io.Writer }); ok {
+		return w.writer()
+	}
+	return ioutil.Discard
+}
@@ -0,0 +1,124 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	// what goleak says when it finds goroutines left running
+	leakRx = regexp.MustCompile(`found unexpected goroutines`)
+	// each leaked goroutine goleak lists starts like this
+	leakedRx = regexp.MustCompile(`^(\s*)\[Goroutine (\d+) in state ([^,]+), with (\S+) on top of the stack:\s*$`)
+	// a frame's file:line, as in a goroutine dump
+	leakFileRx = regexp.MustCompile(`^\s+(\S+:\d+)`)
+)
+
+// leaks keeps track of the tests (and packages) that goleak found to
+// leave goroutines behind, which tends to point at a resource bug more
+// than at a logic one.
+type leaks struct {
+	names      []string
+	goroutines map[string]int
+	total      int
+}
+
+func newLeaks() *leaks {
+	return &leaks{goroutines: map[string]int{}}
+}
+
+// check looks for goleak's complaints in the output of a failed test.
+func (l *leaks) check(name string, output []string) {
+	found := false
+	n := 0
+	for _, line := range output {
+		if leakRx.MatchString(line) {
+			found = true
+		} else if found && leakedRx.MatchString(line) {
+			n++
+		}
+	}
+	if !found {
+		return
+	}
+	if _, seen := l.goroutines[name]; !seen {
+		l.names = append(l.names, name)
+	}
+	l.goroutines[name] += n
+	l.total += n
+}
+
+// add looks for goleak's complaints in what packages say outside of
+// any one test, e.g. from goleak.VerifyTestMain.
+func (l *leaks) add(ev *TestEvent) {
+	if ev.Test != "" || ev.Action != "output" {
+		return
+	}
+	name := ev.pkg()
+	if leakRx.MatchString(ev.Output) {
+		l.check(name, []string{ev.Output})
+	} else if _, seen := l.goroutines[name]; seen && leakedRx.MatchString(ev.Output) {
+		l.goroutines[name]++
+		l.total++
+	}
+}
+
+// isZero returns whether no leaks were found.
+func (l *leaks) isZero() bool {
+	return len(l.names) == 0
+}
+
+func (l *leaks) report(w io.Writer, esc *escape) {
+	if l.isZero() {
+		return
+	}
+	fmt.Fprintf(w, "\n%sGoroutine leaks%s: ", esc.fail, esc.endc)
+	if l.total > 0 {
+		fmt.Fprintf(w, "%s left behind by ", gn("goroutine", "goroutines")(l.total))
+	} else {
+		fmt.Fprint(w, "found in ")
+	}
+	fmt.Fprintf(w, "%s:\n", gn("test", "tests")(len(l.names)))
+	for _, name := range l.names {
+		if n := l.goroutines[name]; n > 0 {
+			fmt.Fprintf(w, "  %s (%d)\n", name, n)
+		} else {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+}
+
+// collapseLeaks boils each of the goroutines goleak lists down to a
+// line saying what it's doing, and one saying where it came from.
+func collapseLeaks(lines []string) []string {
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		m := leakedRx.FindStringSubmatch(lines[i])
+		if m == nil {
+			out = append(out, lines[i])
+			continue
+		}
+		indent, id, state, top := m[1], m[2], m[3], m[4]
+		out = append(out, fmt.Sprintf("%sleaked goroutine %s (%s) in %s\n", indent, id, state, top))
+		j := i + 1
+		for ; j < len(lines) && strings.TrimSpace(lines[j]) != "]"; j++ {
+			fn := strings.TrimSpace(lines[j])
+			if !strings.HasPrefix(fn, "created by ") || j+1 >= len(lines) {
+				continue
+			}
+			where := ""
+			if m := leakFileRx.FindStringSubmatch(lines[j+1]); m != nil {
+				where = " at " + m[1]
+			}
+			out = append(out, fmt.Sprintf("%s  %s%s\n", indent, fn, where))
+		}
+		i = j
+	}
+	return out
+}
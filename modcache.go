@@ -0,0 +1,102 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// stateFile returns where goctest keeps the named bit of state between
+// runs, as per the XDG base directory spec.
+func stateFile(name string) (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if !filepath.IsAbs(dir) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "goctest", name), nil
+}
+
+// findGoMod returns the go.mod of the module the directory is in, if any.
+func findGoMod(dir string) string {
+	for {
+		gomod := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(gomod); err == nil {
+			return gomod
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// a modEntry is what's remembered of ‘go list -m’ in a directory
+type modEntry struct {
+	// Stamp is the mtime of the go.mod it was worked out from
+	Stamp  int64  `json:"stamp"`
+	Module string `json:"module"`
+}
+
+// modulePrefix returns what ‘go list -m’ says the current module is.
+// As that can take a while on big repos the answer is remembered, per
+// directory, for as long as its go.mod is left alone.
+func modulePrefix(ctx context.Context) (string, bool) {
+	list := func() (string, bool) {
+		out, err := exec.CommandContext(ctx, "go", "list", "-m").Output()
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimSpace(string(out)), true
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return list()
+	}
+	gomod := findGoMod(cwd)
+	if gomod == "" {
+		return list()
+	}
+	fi, err := os.Stat(gomod)
+	if err != nil {
+		return list()
+	}
+	stamp := fi.ModTime().UnixNano()
+	path, err := stateFile("modules.json")
+	if err != nil {
+		return list()
+	}
+	cache := map[string]modEntry{}
+	if buf, err := ioutil.ReadFile(path); err == nil {
+		// a broken cache is as good as none
+		json.Unmarshal(buf, &cache)
+	}
+	if entry, ok := cache[cwd]; ok && entry.Stamp == stamp {
+		return entry.Module, true
+	}
+	mod, ok := list()
+	if !ok {
+		return mod, ok
+	}
+	cache[cwd] = modEntry{Stamp: stamp, Module: mod}
+	// not being able to remember it is no reason to stop
+	if buf, err := json.Marshal(cache); err == nil && os.MkdirAll(filepath.Dir(path), 0755) == nil {
+		tmp := path + ".tmp"
+		if ioutil.WriteFile(tmp, buf, 0644) == nil {
+			os.Rename(tmp, path)
+		}
+	}
+	return mod, ok
+}
@@ -0,0 +1,65 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// muted packages are dropped on the floor, as if they'd never been
+// run. A nil muted mutes nothing.
+type muted struct {
+	prefixes []string
+	seen     map[string]bool
+}
+
+// addMuted adds the comma-separated package prefixes in spec to m
+// (making it if need be).
+func addMuted(m *muted, spec string) *muted {
+	if m == nil {
+		m = &muted{seen: map[string]bool{}}
+	}
+	for _, prefix := range strings.Split(spec, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			m.prefixes = append(m.prefixes, prefix)
+		}
+	}
+	return m
+}
+
+// mutes says whether the event is from a muted package, going by its
+// full (untrimmed) import path.
+func (m *muted) mutes(ev *TestEvent) bool {
+	if m == nil {
+		return false
+	}
+	pkg := ev.Package
+	if pkg == "" {
+		pkg = ev.ImportPath
+		if idx := strings.IndexByte(pkg, ' '); idx > -1 {
+			pkg = pkg[:idx]
+		}
+	}
+	if pkg == "" {
+		return false
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			m.seen[pkg] = true
+			return true
+		}
+	}
+	return false
+}
+
+// report says how many packages were muted, so it's not a secret.
+func (m *muted) report(w io.Writer, esc *escape) {
+	if m == nil || len(m.seen) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s(%s muted)%s\n", esc.skip, gn("package", "packages")(len(m.seen)), esc.endc)
+}
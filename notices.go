@@ -0,0 +1,65 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// notices picks out the lines of passing tests' output that match a
+// regexp (e.g. deprecation warnings), that would otherwise go unseen. A
+// nil notices does nothing.
+type notices struct {
+	rxs   []*regexp.Regexp
+	names []string
+	lines map[string][]string
+}
+
+func newNotices(rxs []*regexp.Regexp) *notices {
+	return &notices{rxs: rxs, lines: map[string][]string{}}
+}
+
+// matches returns whether the line matches any of the regexps.
+func (n *notices) matches(line string) bool {
+	line = strings.TrimRight(line, "\n")
+	for _, rx := range n.rxs {
+		if rx.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// add looks through the output of a test that passed.
+func (n *notices) add(name string, output []string) {
+	if n == nil {
+		return
+	}
+	for _, line := range output {
+		if !n.matches(line) {
+			continue
+		}
+		if _, ok := n.lines[name]; !ok {
+			n.names = append(n.names, name)
+		}
+		n.lines[name] = append(n.lines[name], line)
+	}
+}
+
+func (n *notices) report(w io.Writer, esc *escape) {
+	if n == nil || len(n.names) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nNotices from %s that passed:\n", gn("test", "tests")(len(n.names)))
+	for _, name := range n.names {
+		fmt.Fprintf(w, "  %s\n", esc.em(name))
+		for _, line := range n.lines[name] {
+			fmt.Fprint(w, "    ", line)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+// with more than one ‘--notice’, lines matching any of them are noticed
+func TestNotices(t *testing.T) {
+	n := newNotices([]*regexp.Regexp{regexp.MustCompile(`deprecated`), regexp.MustCompile(`^\s+\S+: slow$`)})
+	n.add("a:TestA", []string{"=== RUN   TestA\n", "    a_test.go:7: Foo is deprecated\n", "--- PASS: TestA (0.00s)\n"})
+	n.add("a:TestB", []string{"=== RUN   TestB\n", "    a_test.go:12: slow\n", "--- PASS: TestB (0.00s)\n"})
+	n.add("a:TestC", []string{"=== RUN   TestC\n", "    a_test.go:17: fine\n", "--- PASS: TestC (0.00s)\n"})
+
+	var buf bytes.Buffer
+	n.report(&buf, escapes[testEsc])
+	expected := `
+Notices from 2 tests that passed:
+  *a:TestA*
+        a_test.go:7: Foo is deprecated
+  *a:TestB*
+        a_test.go:12: slow
+`
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// thousands separates groups of digits in the numbers in summaries;
+// empty means not to.
+var thousands = ""
+
+// num formats a count for people to read.
+func num(n int) string {
+	s := strconv.Itoa(n)
+	if thousands == "" {
+		return s
+	}
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, d := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(thousands)
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// localThousands guesses the thousands separator from the locale, the
+// way setlocale would pick which one to look at.
+func localThousands() string {
+	var locale string
+	for _, k := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if locale = os.Getenv(k); locale != "" {
+			break
+		}
+	}
+	if locale == "" || locale == "C" || locale == "POSIX" || strings.HasPrefix(locale, "C.") {
+		return ""
+	}
+	lang := locale
+	if idx := strings.IndexAny(lang, "_.@"); idx > -1 {
+		lang = lang[:idx]
+	}
+	switch lang {
+	case "de", "es", "it", "nl", "pt", "da", "id", "tr", "el":
+		return "."
+	case "fr", "ru", "pl", "cs", "sk", "sv", "fi", "nb", "nn", "uk", "hu", "bg":
+		return " "
+	default:
+		return ","
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// options are what the command line asked of goctest. Flags that aren't
+// goctest's own are kept in goArgs, for ‘go test’.
+type options struct {
+	escOverride     string
+	failEscOverride string
+	format          string
+	glyphName       string
+	banner          string
+	bannerSize      string
+	greenAt         int
+	redAt           int
+	coloursSet      bool
+	denominator     string
+	summaryAt       string
+	sortPkgs        string
+	failsJSONL      string
+	timingsFile     string
+	summaryFile     string
+	gobFile         string
+	flakyFile       string
+	label           string
+	marked          highlights
+	noticeRxs       []*regexp.Regexp
+	artifactPrefix  string
+	prefix          string
+	baselineFile    string
+	ownersFile      string
+	since           string
+	shard           string
+	shardTimings    string
+	thousands       string
+	thousandsSet    bool
+	filterSpec      string
+	alsoPlain       string
+	mutes           []string
+	maxFails        int
+	contextTests    int
+	maxPkgOutput    int
+	stallAfter      string
+	pkgLimit        string
+	stallAction     string
+	maxOutputBytes  int
+	compiled        string
+	binaries        []string
+	poll            string
+	debounce        string
+
+	// verbosity is ‘quiet’ for -q, ‘verbose’ for -v, and empty otherwise
+	verbosity        string
+	fromStdin        bool
+	sniff            bool
+	prettyPanics     bool
+	prettyLeaks      bool
+	diffHighlight    bool
+	testify          bool
+	noTrim           bool
+	noBuildOutput    bool
+	quietErrors      bool
+	flaky            bool
+	skipReasons      bool
+	failOnSkip       bool
+	shortSkipsOK     bool
+	pkgOutput        bool
+	showBuildTime    bool
+	relativeTime     bool
+	profile          bool
+	dryRun           bool
+	echoCommand      bool
+	animate          bool
+	deterministic    bool
+	strictJSON       bool
+	timestamps       bool
+	columns          bool
+	collapse         bool
+	usePager         bool
+	keepGoing        bool
+	rerunOnly        bool
+	tiny             bool
+	porcelain        bool
+	lazy             bool
+	demo             bool
+	stripANSI        bool
+	reverse          bool
+	wrapLines        bool
+	wantEnv          bool
+	countParents     bool
+	leavesOnly       bool
+	merge            bool
+	separateStderr   bool
+	onlyChangedTests bool
+	quietSkips       bool
+	hideEmpty        bool
+	strictExit       bool
+	watch            bool
+	watchFailedFirst bool
+	help             bool
+	version          bool
+
+	goArgs []string
+	// ownArgs are goctest's own flags as given, but for the ones about
+	// watching, for running goctest again
+	ownArgs []string
+}
+
+func defaultOptions() *options {
+	return &options{
+		glyphName:      "default",
+		banner:         "percent",
+		bannerSize:     "normal",
+		greenAt:        100,
+		denominator:    "non-skipped",
+		summaryAt:      "bottom",
+		prefix:         unsetPrefix,
+		stallAction:    "warn",
+		maxOutputBytes: 256 << 20,
+		poll:           "250ms",
+		debounce:       "300ms",
+	}
+}
+
+// parse goes over the command line (without the command itself) once,
+// in order, so later flags win over earlier ones. Everything after a
+// ‘--’ is for ‘go test’; nothing after ‘-h’ or ‘--version’ is looked at.
+func (o *options) parse(argv []string) {
+	for i := 0; i < len(argv); i++ {
+		start, arg := i, argv[i]
+		if arg == "--" {
+			o.goArgs = append(o.goArgs, argv[i+1:]...)
+			break
+		}
+		flag := arg
+		idx := strings.IndexByte(arg, '=')
+		if idx > -1 {
+			flag = arg[:idx]
+		}
+		// value is the flag's, be it after the ‘=’ or the next argument
+		value := func() string {
+			if idx > -1 {
+				return arg[idx+1:]
+			}
+			if i+1 == len(argv) {
+				log.Fatalf("The flag ‘%s’ needs a value.", flag)
+			}
+			i++
+			return argv[i]
+		}
+		if o.setValue(flag, value) {
+			o.keepOwn(flag, argv[start:i+1])
+			continue
+		}
+		// ‘--flag=value’ for a flag that doesn't take one is go's
+		if idx > -1 || !o.set(arg) {
+			o.goArgs = append(o.goArgs, arg)
+		} else {
+			o.keepOwn(arg, argv[start:i+1])
+		}
+		if o.help || o.version {
+			break
+		}
+	}
+}
+
+// keepOwn keeps the arguments for one of goctest's flags, unless it's
+// about watching.
+func (o *options) keepOwn(flag string, args []string) {
+	switch flag {
+	case "--watch", "--watch-run-failed-first", "--poll", "--debounce":
+		return
+	}
+	o.ownArgs = append(o.ownArgs, args...)
+}
+
+// setValue sets the option for a flag that takes a value, and says
+// whether it was one.
+func (o *options) setValue(flag string, value func() string) bool {
+	switch flag {
+	case "--esc":
+		o.escOverride = value()
+	case "--fail-esc":
+		o.failEscOverride = value()
+	case "--format":
+		o.format = value()
+	case "--glyphs":
+		o.glyphName = value()
+	case "--banner":
+		o.banner = value()
+	case "--banner-size":
+		o.bannerSize = value()
+	case "--green-at":
+		o.greenAt, o.coloursSet = atoi(flag, value()), true
+	case "--red-at":
+		o.redAt, o.coloursSet = atoi(flag, value()), true
+	case "--pct-denominator":
+		o.denominator = value()
+	case "--summary":
+		o.summaryAt = value()
+	case "--sort-pkgs":
+		o.sortPkgs = value()
+	case "--fails-jsonl":
+		o.failsJSONL = value()
+	case "--timings":
+		o.timingsFile = value()
+	case "--summary-json":
+		o.summaryFile = value()
+	case "--gob":
+		o.gobFile = value()
+	case "--write-flaky":
+		o.flakyFile = value()
+	case "--label":
+		o.label = value()
+	case "--highlight":
+		o.marked = append(o.marked, mustCompile(flag, value()))
+	case "--notice":
+		o.noticeRxs = append(o.noticeRxs, mustCompile(flag, value()))
+	case "--artifact-prefix":
+		o.artifactPrefix = value()
+	case "--trim":
+		o.prefix = value()
+	case "--baseline":
+		o.baselineFile = value()
+	case "--owners":
+		o.ownersFile = value()
+	case "--since":
+		o.since = value()
+	case "--shard":
+		o.shard = value()
+	case "--shard-timings":
+		o.shardTimings = value()
+	case "--thousands":
+		o.thousands, o.thousandsSet = value(), true
+	case "--filter":
+		o.filterSpec = value()
+	case "--also-plain":
+		o.alsoPlain = value()
+	case "--mute":
+		o.mutes = append(o.mutes, value())
+	case "--max-fails":
+		o.maxFails = atoi(flag, value())
+	case "--context-tests":
+		o.contextTests = atoi(flag, value())
+	case "--max-package-output":
+		o.maxPkgOutput = atoi(flag, value())
+	case "--stall":
+		o.stallAfter = value()
+	case "--pkg-deadline":
+		o.pkgLimit = value()
+	case "--stall-action":
+		o.stallAction = value()
+	case "--max-output-bytes":
+		o.maxOutputBytes = atoi(flag, value())
+	case "--poll":
+		o.poll = value()
+	case "--debounce":
+		o.debounce = value()
+	case "-c":
+		o.compiled = value()
+		o.binaries = append(o.binaries, o.compiled)
+	default:
+		return false
+	}
+	return true
+}
+
+// set sets the option for a flag that takes no value, and says whether
+// it was one.
+func (o *options) set(flag string) bool {
+	switch flag {
+	case "-":
+		o.fromStdin = true
+	case "--stdin":
+		o.sniff = true
+	case "-q":
+		o.verbosity = "quiet"
+	case "-v":
+		o.verbosity = "verbose"
+	case "--pretty-panics":
+		o.prettyPanics = true
+	case "--pretty-leaks":
+		o.prettyLeaks = true
+	case "--diff-highlight":
+		o.diffHighlight = true
+	case "--testify":
+		o.testify = true
+	case "--no-trim":
+		o.noTrim = true
+	case "--no-build-output":
+		o.noBuildOutput = true
+	case "--quiet-errors":
+		o.quietErrors = true
+		o.noBuildOutput = true
+	case "--flaky":
+		o.flaky = true
+	case "--skip-reasons":
+		o.skipReasons = true
+	case "--fail-on-skip":
+		o.failOnSkip = true
+	case "--short-skips-ok":
+		o.shortSkipsOK = true
+	case "--pkg-output":
+		o.pkgOutput = true
+	case "--show-build-time":
+		o.showBuildTime = true
+	case "--relative-time":
+		o.relativeTime = true
+	case "--profile":
+		o.profile = true
+	case "--dry-run":
+		o.dryRun = true
+	case "--echo-command":
+		o.echoCommand = true
+	case "--animate":
+		o.animate = true
+	case "--deterministic":
+		o.deterministic = true
+	case "--strict-json":
+		o.strictJSON = true
+	case "--timestamps":
+		o.timestamps = true
+	case "--columns":
+		o.columns = true
+	case "--collapse":
+		o.collapse = true
+	case "--pager":
+		o.usePager = true
+	case "--keep-going":
+		o.keepGoing = true
+	case "--rerun-cmd-only":
+		o.rerunOnly = true
+	case "--tiny":
+		o.tiny = true
+	case "--porcelain":
+		o.porcelain = true
+	case "--lazy":
+		o.lazy = true
+	case "--demo":
+		o.demo = true
+	case "--strip-ansi":
+		o.stripANSI = true
+	case "--reverse":
+		o.reverse = true
+	case "--wrap":
+		o.wrapLines = true
+	case "--env-info":
+		o.wantEnv = true
+	case "--count-parents":
+		o.countParents = true
+		o.leavesOnly = false
+	case "--leaves-only":
+		o.countParents = false
+		o.leavesOnly = true
+	case "--merge":
+		o.merge = true
+	case "--separate-stderr":
+		o.separateStderr = true
+	case "--only-changed-tests":
+		o.onlyChangedTests = true
+	case "--quiet-skips":
+		o.quietSkips = true
+	case "--hide-empty":
+		o.hideEmpty = true
+	case "--strict-exit":
+		o.strictExit = true
+	case "--watch":
+		o.watch = true
+	case "--watch-run-failed-first":
+		o.watch = true
+		o.watchFailedFirst = true
+	case "-json":
+		// goctest always asks for it
+	case "-h", "-help", "--help":
+		o.help = true
+	case "-version", "--version":
+		o.version = true
+	default:
+		return false
+	}
+	return true
+}
+
+// check dies if any of the options that need to be one of a few things
+// isn't.
+func (o *options) check() {
+	switch o.banner {
+	case "percent", "fraction", "both":
+	default:
+		log.Fatalf("The flag ‘--banner’ needs one of ‘percent’, ‘fraction’, or ‘both’, not %q.", o.banner)
+	}
+	switch o.bannerSize {
+	case "small", "normal", "large":
+	default:
+		log.Fatalf("The flag ‘--banner-size’ needs one of ‘small’, ‘normal’, or ‘large’, not %q.", o.bannerSize)
+	}
+	if o.greenAt > 100 {
+		log.Fatalf("The flag ‘--green-at’ needs a percentage, not %d.", o.greenAt)
+	}
+	if o.redAt >= o.greenAt {
+		log.Fatalf("The flag ‘--red-at’ needs to be below ‘--green-at’ (%d), not %d.", o.greenAt, o.redAt)
+	}
+	switch o.denominator {
+	case "non-skipped", "total":
+	default:
+		log.Fatalf("The flag ‘--pct-denominator’ needs one of ‘non-skipped’ or ‘total’, not %q.", o.denominator)
+	}
+	switch o.summaryAt {
+	case "top", "bottom", "both":
+	default:
+		log.Fatalf("The flag ‘--summary’ needs one of ‘top’, ‘bottom’, or ‘both’, not %q.", o.summaryAt)
+	}
+	switch o.sortPkgs {
+	case "", "time":
+	default:
+		log.Fatalf("The flag ‘--sort-pkgs’ only knows about ‘time’, not %q.", o.sortPkgs)
+	}
+}
+
+// summary returns a summary to add things up in as the options say. The
+// banner's colours are only moved about if asked to, as even moving them
+// to where they'd be anyway (100 and 0) changes the blend.
+func (o *options) summary() summary {
+	ss := summary{
+		banner:       o.banner,
+		size:         o.bannerSize,
+		countParents: o.countParents,
+		countSkips:   o.denominator == "total",
+	}
+	if o.coloursSet {
+		ss.greenAt, ss.redAt = o.greenAt, o.redAt
+	}
+	return ss
+}
+
+// mustCompile compiles the value of a regexp flag, or dies trying.
+func mustCompile(flag, v string) *regexp.Regexp {
+	rx, err := regexp.Compile(v)
+	if err != nil {
+		log.Fatalf("The flag ‘%s’ needs a regular expression, not %q (%v).", flag, v, err)
+	}
+	return rx
+}
+
+// atoi parses the value of a numeric flag, or dies trying.
+func atoi(flag, v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("The flag ‘%s’ needs a non-negative number, not %q.", flag, v)
+	}
+	return n
+}
@@ -0,0 +1,74 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	for _, tc := range []struct {
+		argv   []string
+		check  func(o *options) bool
+		goArgs []string
+	}{
+		{
+			// a value can come after an ‘=’ or as the next argument
+			argv: []string{"--esc=test", "--fail-esc", "bare", "--green-at", "90", "--red-at=10"},
+			check: func(o *options) bool {
+				return o.escOverride == "test" && o.failEscOverride == "bare" && o.greenAt == 90 && o.redAt == 10
+			},
+		}, {
+			// both ‘--notice’s count, not just the last
+			argv: []string{"--notice", "deprecated", "--notice=slow"},
+			check: func(o *options) bool {
+				return len(o.noticeRxs) == 2 && o.noticeRxs[0].String() == "deprecated" && o.noticeRxs[1].String() == "slow"
+			},
+		}, {
+			argv:  []string{"--highlight=foo", "--highlight", "bar", "--mute", "a,b", "--mute=c"},
+			check: func(o *options) bool { return len(o.marked) == 2 && reflect.DeepEqual(o.mutes, []string{"a,b", "c"}) },
+		}, {
+			// later flags win
+			argv:  []string{"-q", "-v", "--leaves-only", "--count-parents"},
+			check: func(o *options) bool { return o.verbosity == "verbose" && o.countParents && !o.leavesOnly },
+		}, {
+			argv:  []string{"--quiet-errors", "-c", "a.test", "-c=b.test"},
+			check: func(o *options) bool { return o.quietErrors && o.noBuildOutput && o.compiled == "b.test" },
+		}, {
+			// anything that isn't goctest's goes to go test, as does
+			// giving a value to a flag that doesn't take one
+			argv:   []string{"-run=TestA", "-count", "1", "--flaky=true", "-json", "./..."},
+			check:  func(o *options) bool { return !o.flaky },
+			goArgs: []string{"-run=TestA", "-count", "1", "--flaky=true", "./..."},
+		}, {
+			// after ‘--’ it's all go's
+			argv:   []string{"--tiny", "--", "--porcelain", "-h"},
+			check:  func(o *options) bool { return o.tiny && !o.porcelain && !o.help },
+			goArgs: []string{"--porcelain", "-h"},
+		}, {
+			// goctest's own flags are kept to run it again with, but
+			// for the ones about watching
+			argv: []string{"--watch", "--tiny", "--poll", "1s", "--esc=bare", "--debounce=1s", "-run", "TestA"},
+			check: func(o *options) bool {
+				return o.watch && o.poll == "1s" && o.debounce == "1s" && reflect.DeepEqual(o.ownArgs, []string{"--tiny", "--esc=bare"})
+			},
+			goArgs: []string{"-run", "TestA"},
+		}, {
+			// and after ‘-h’ nothing is looked at
+			argv:  []string{"--tiny", "--help", "--green-at=nope", "--porcelain"},
+			check: func(o *options) bool { return o.help && o.tiny && !o.porcelain && o.greenAt == 100 },
+		},
+	} {
+		o := defaultOptions()
+		o.parse(tc.argv)
+		if !tc.check(o) {
+			t.Errorf("%q: got %+v", tc.argv, o)
+		}
+		if !reflect.DeepEqual(o.goArgs, tc.goArgs) {
+			t.Errorf("%q: expected %q for go test, got %q", tc.argv, tc.goArgs, o.goArgs)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const noOwner = "(no owner)"
+
+// owners maps package path prefixes to the teams that own them.
+type owners struct {
+	teams map[string]string
+}
+
+// loadOwners reads an owners file: one package path prefix per line,
+// followed by whitespace and the name of the team that owns it. Blank
+// lines and lines starting with ‘#’ are ignored.
+func loadOwners(path string) (*owners, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	o := &owners{teams: map[string]string{}}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected a package prefix and a team", path, n)
+		}
+		o.teams[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// owner returns the team that owns the package, going by the longest
+// prefix that matches.
+func (o *owners) owner(pkg string) string {
+	best := ""
+	team := noOwner
+	for prefix, t := range o.teams {
+		if strings.HasPrefix(pkg, prefix) && len(prefix) > len(best) {
+			best = prefix
+			team = t
+		}
+	}
+	return team
+}
+
+// report writes how many of the given packages each team owns.
+func (o *owners) report(w io.Writer, pkgs []string) {
+	if o == nil || len(pkgs) == 0 {
+		return
+	}
+	counts := map[string]int{}
+	var teams []string
+	for _, pkg := range pkgs {
+		team := o.owner(pkg)
+		if counts[team] == 0 {
+			teams = append(teams, team)
+		}
+		counts[team]++
+	}
+	sort.SliceStable(teams, func(i, j int) bool {
+		return counts[teams[i]] > counts[teams[j]]
+	})
+	n := gn("failing package", "failing packages")
+	fmt.Fprintln(w, "\nFailing packages, by owner:")
+	for _, team := range teams {
+		fmt.Fprintf(w, "  %s: %s\n", team, n(counts[team]))
+	}
+}
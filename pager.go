@@ -0,0 +1,59 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// a pager pages through whatever is written to it.
+type pager struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+// startPager starts $PAGER, or less if that's not set. It returns nil
+// if stdout isn't a terminal, or if there's no pager to be had.
+func startPager() *pager {
+	if !isTerminal(os.Stdout) {
+		return nil
+	}
+	argv := strings.Fields(os.Getenv("PAGER"))
+	if len(argv) == 0 {
+		if _, err := exec.LookPath("less"); err != nil {
+			return nil
+		}
+		argv = []string{"less"}
+	}
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	// make sure less lets the colours through (and, unless told
+	// otherwise, gets out of the way when it all fits on one screen)
+	less, set := os.LookupEnv("LESS")
+	if !set {
+		less = "FRX"
+	} else if !strings.Contains(less, "R") {
+		less += "R"
+	}
+	cmd.Env = append(os.Environ(), "LESS="+less)
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil
+	}
+	if err := cmd.Start(); err != nil {
+		return nil
+	}
+	return &pager{WriteCloser: w, cmd: cmd}
+}
+
+// Close closes the pager's input and waits for the user to be done.
+func (p *pager) Close() error {
+	p.WriteCloser.Close()
+	return p.cmd.Wait()
+}
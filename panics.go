@@ -0,0 +1,66 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var goroutineRx = regexp.MustCompile(`^goroutine \d+ \[[^]]*\]:\s*$`)
+
+// isFrame returns whether the two lines look like a frame in a
+// goroutine dump: a function call, followed by an indented file:line.
+func isFrame(fn, file string) bool {
+	if fn == "" || fn[0] == ' ' || fn[0] == '\t' || fn[0] == '\n' {
+		return false
+	}
+	return strings.HasPrefix(file, "\t")
+}
+
+// isMine returns whether the function in a frame belongs to the code
+// being tested, i.e. to the module given by prefix or, if that's not
+// known, to anything that's not the standard library.
+func isMine(fn, prefix string) bool {
+	fn = strings.TrimPrefix(fn, "created by ")
+	if prefix != "" && prefix != unsetPrefix {
+		return strings.HasPrefix(fn, prefix)
+	}
+	idx := strings.IndexByte(fn, '/')
+	if idx == -1 {
+		// no path at all: either the standard library, or package main
+		return strings.HasPrefix(fn, "main.")
+	}
+	// the standard library has no dots in the first path element
+	return strings.Contains(fn[:idx], ".")
+}
+
+// collapsePanics trims the goroutine dumps found in the given output
+// to just the frames that belong to the code being tested, noting how
+// many frames it dropped.
+func collapsePanics(lines []string, prefix string) []string {
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		out = append(out, lines[i])
+		if !goroutineRx.MatchString(lines[i]) {
+			continue
+		}
+		dropped := 0
+		j := i + 1
+		for ; j+1 < len(lines) && isFrame(lines[j], lines[j+1]); j += 2 {
+			if isMine(lines[j], prefix) {
+				out = append(out, lines[j], lines[j+1])
+			} else {
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			out = append(out, fmt.Sprintf("\t(+%d runtime frames)\n", dropped))
+		}
+		i = j - 1
+	}
+	return out
+}
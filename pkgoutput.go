@@ -0,0 +1,59 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// runnerRx matches the lines the test runner itself writes at the
+// package level, as opposed to what the package's code does.
+var runnerRx = regexp.MustCompile(`^(?:PASS|FAIL|ok  \t.*|FAIL\t.*|\?   \t.*|coverage: .*|testing: warning: no tests to run)\n?$`)
+
+// pkgOutput collects what packages write outside of any one test, e.g.
+// from TestMain. A nil pkgOutput does nothing.
+type pkgOutput struct {
+	pkgs   []string
+	output map[string][]string
+	// max is how many lines to keep per package (0 is all of them),
+	// and dropped how many were not kept
+	max     int
+	dropped map[string]int
+}
+
+func newPkgOutput() *pkgOutput {
+	return &pkgOutput{output: map[string][]string{}, dropped: map[string]int{}}
+}
+
+func (p *pkgOutput) add(ev *TestEvent) {
+	if p == nil || ev.Test != "" || ev.Action != "output" || runnerRx.MatchString(ev.Output) {
+		return
+	}
+	if _, ok := p.output[ev.Package]; !ok {
+		p.pkgs = append(p.pkgs, ev.Package)
+	}
+	if p.max > 0 && len(p.output[ev.Package]) >= p.max {
+		p.dropped[ev.Package]++
+		return
+	}
+	p.output[ev.Package] = append(p.output[ev.Package], ev.pkg()+": "+ev.Output)
+}
+
+func (p *pkgOutput) report(w io.Writer) {
+	if p == nil || len(p.pkgs) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nOutput from the packages themselves:")
+	for _, pkg := range p.pkgs {
+		for _, line := range p.output[pkg] {
+			fmt.Fprint(w, "  ", line)
+		}
+		if n := p.dropped[pkg]; n > 0 {
+			fmt.Fprintf(w, "  [%s from %s dropped]\n", gn("line", "lines")(n), pkg)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// porcelainProgress writes one line per finished test or package, for
+// scripts to read. The format is a contract: each line is
+//
+//	STATUS<TAB>PACKAGE<TAB>TEST<TAB>ELAPSED
+//
+// where STATUS is one of PASS, FAIL, SKIP, ERROR, XFAIL, XPASS or FLAKY;
+// PACKAGE is the full import path; TEST is empty for a package; and
+// ELAPSED is in seconds. Fields may be added at the end in the future,
+// but these won't change. There are no colours, and no summary.
+type porcelainProgress struct {
+	escape
+	layout
+	sink
+}
+
+func (p *porcelainProgress) report(ev *TestEvent) {
+	switch ev.Action {
+	case "pass", "fail", "skip", "error", "xfail", "xpass", "flaky":
+	default:
+		return
+	}
+	test := ev.Test
+	if test == errorPlaceholder {
+		test = ""
+	}
+	fmt.Fprintf(p.writer(), "%s\t%s\t%s\t%s\n",
+		strings.ToUpper(ev.Action), ev.Package, test, strconv.FormatFloat(ev.Elapsed, 'f', -1, 64))
+}
+
+func (*porcelainProgress) summarize(*summary) {}
@@ -0,0 +1,68 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// a profile keeps track of where goctest spends its time: waiting for
+// the test runner to say something, or dealing with what it said.
+// A nil profile does nothing.
+type profile struct {
+	start   time.Time
+	mark    time.Time
+	waiting time.Duration
+	working time.Duration
+	lines   int
+}
+
+func newProfile() *profile {
+	now := time.Now()
+	return &profile{start: now, mark: now}
+}
+
+// waited is called once a line has been read.
+func (p *profile) waited() {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	p.waiting += now.Sub(p.mark)
+	p.mark = now
+	p.lines++
+}
+
+// worked is called when done with whatever was read.
+func (p *profile) worked() {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	p.working += now.Sub(p.mark)
+	p.mark = now
+}
+
+func (p *profile) report(w io.Writer) {
+	if p == nil {
+		return
+	}
+	p.worked()
+	total := p.mark.Sub(p.start)
+	pct := func(d time.Duration) float64 {
+		if total <= 0 {
+			return 0
+		}
+		return 100 * float64(d) / float64(total)
+	}
+	fmt.Fprintf(w, "goctest profile: %d lines in %s\n", p.lines, total.Round(time.Microsecond))
+	fmt.Fprintf(w, "  waiting: %12s (%5.1f%%)\n", p.waiting.Round(time.Microsecond), pct(p.waiting))
+	fmt.Fprintf(w, "  working: %12s (%5.1f%%)\n", p.working.Round(time.Microsecond), pct(p.working))
+	if p.lines > 0 {
+		fmt.Fprintf(w, "  per line: %11s\n", (p.working / time.Duration(p.lines)).Round(time.Nanosecond))
+	}
+}
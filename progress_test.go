@@ -0,0 +1,219 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// check a reporter writes where it's told to, and not to stdout
+func TestDefaultProgressOutput(t *testing.T) {
+	var buf bytes.Buffer
+	p := &defaultProgress{}
+	p.setEscape("test")
+	p.setLayout(0, glyphSets["ascii"])
+	p.setOutput(&buf)
+
+	var ss summary
+	for _, ev := range []TestEvent{
+		{Action: "pass", Package: "example.com/a", Test: "TestA"},
+		{Action: "pass", Package: "example.com/a"},
+		{Action: "fail", Package: "example.com/b", Test: "TestB"},
+		{Action: "fail", Package: "example.com/b"},
+		{Action: "skip", Package: "example.com/c"},
+	} {
+		ev := ev
+		ev.prefix = "example.com"
+		p.report(&ev)
+		ss.add(&ev)
+	}
+	p.summarize(&ss)
+
+	lines := strings.Split(buf.String(), "\n")
+	expected := []string{
+		"PASS+ENDC …/a",
+		"FAILxENDC …/b",
+		"SKIP- …/cENDC",
+		"Found 2 tests in 3 packages (1 package had SKIPNO testsENDC).",
+		"1 tests PASSpassedENDC, and 1 tests FAILfailedENDC.",
+	}
+	if len(lines) < len(expected) {
+		t.Fatalf("expected at least %d lines, got %q", len(expected), buf.String())
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("line %d is %q, expected %q", i, lines[i], line)
+		}
+	}
+}
+
+// with --summary top the progress report is shown as it happens, and
+// held on to as well to show again after the summary; with --reverse it's
+// only held
+func TestProgressHeld(t *testing.T) {
+	for _, reverse := range []bool{false, true} {
+		var screen, held bytes.Buffer
+		p := &defaultProgress{}
+		p.setEscape("test")
+		p.setLayout(0, glyphSets["ascii"])
+		p.setOutput(progressOut(&screen, &held, reverse))
+
+		var ss summary
+		for _, ev := range []TestEvent{
+			{Action: "pass", Package: "example.com/a", Test: "TestA"},
+			{Action: "pass", Package: "example.com/a"},
+			{Action: "fail", Package: "example.com/b", Test: "TestB"},
+			{Action: "fail", Package: "example.com/b"},
+		} {
+			ev := ev
+			ev.prefix = "example.com"
+			p.report(&ev)
+			ss.add(&ev)
+		}
+		progress := "PASS+ENDC …/a\nFAILxENDC …/b\n"
+		if held.String() != progress {
+			t.Errorf("reverse=%v: expected %q to be held, got %q", reverse, progress, held.String())
+		}
+		shown := progress
+		if reverse {
+			shown = ""
+		}
+		if screen.String() != shown {
+			t.Errorf("reverse=%v: expected %q to be shown as it happened, got %q", reverse, shown, screen.String())
+		}
+
+		// and at the end, the summary goes to the screen before it
+		p.setOutput(&screen)
+		screen.Reset()
+		p.summarize(&ss)
+		held.WriteTo(&screen)
+		if !strings.HasPrefix(screen.String(), "Found 2 tests") || !strings.HasSuffix(screen.String(), progress) {
+			t.Errorf("reverse=%v: expected the summary and then the progress, got %q", reverse, screen.String())
+		}
+	}
+}
+
+// the porcelain format is a contract; don't change this test lightly
+func TestPorcelainFields(t *testing.T) {
+	var buf bytes.Buffer
+	p := &porcelainProgress{}
+	p.setEscape("test")
+	p.setOutput(&buf)
+
+	for _, ev := range []TestEvent{
+		{Action: "run", Package: "example.com/a", Test: "TestA"},
+		{Action: "output", Package: "example.com/a", Test: "TestA", Output: "hello\n"},
+		{Action: "pass", Package: "example.com/a", Test: "TestA", Elapsed: 0.01},
+		{Action: "pass", Package: "example.com/a", Elapsed: 1.5},
+		{Action: "fail", Package: "example.com/b", Test: "TestB/sub"},
+		{Action: "error", Package: "example.com/c", Test: errorPlaceholder},
+	} {
+		ev := ev
+		ev.prefix = "example.com"
+		p.report(&ev)
+	}
+	p.summarize(&summary{})
+
+	expected := "PASS\texample.com/a\tTestA\t0.01\n" +
+		"PASS\texample.com/a\t\t1.5\n" +
+		"FAIL\texample.com/b\tTestB/sub\t0\n" +
+		"ERROR\texample.com/c\t\t0\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
+
+// parallel tests pause and carry on; that's not a result, nor output
+func TestPauseCont(t *testing.T) {
+	var buf bytes.Buffer
+	p := &defaultProgress{}
+	p.setEscape("test")
+	p.setLayout(0, glyphSets["ascii"])
+	p.setOutput(&buf)
+
+	var ss summary
+	for _, ev := range []TestEvent{
+		{Action: "start", Package: "example.com/a"},
+		{Action: "run", Package: "example.com/a", Test: "TestA"},
+		{Action: "pause", Package: "example.com/a", Test: "TestA"},
+		{Action: "run", Package: "example.com/a", Test: "TestB"},
+		{Action: "pause", Package: "example.com/a", Test: "TestB"},
+		{Action: "cont", Package: "example.com/a", Test: "TestA"},
+		{Action: "cont", Package: "example.com/a", Test: "TestB"},
+		{Action: "pass", Package: "example.com/a", Test: "TestB"},
+		{Action: "fail", Package: "example.com/a", Test: "TestA"},
+		{Action: "fail", Package: "example.com/a"},
+	} {
+		ev := ev
+		ev.prefix = "example.com"
+		p.report(&ev)
+		ss.add(&ev)
+	}
+	p.summarize(&ss)
+
+	if ss.tests.total != 2 || ss.tests.passed != 1 || ss.tests.failed != 1 {
+		t.Errorf("expected 1 of 2 tests to pass, got %+v", ss.tests)
+	}
+	if ss.packages.total != 1 || ss.packages.failed != 1 {
+		t.Errorf("expected the one package to fail, got %+v", ss.packages)
+	}
+	if ss.paused != 2 {
+		t.Errorf("expected 2 tests to have paused, got %d", ss.paused)
+	}
+	if line := strings.SplitN(buf.String(), "\n", 2)[0]; line != "FAILxENDC …/a" {
+		t.Errorf("expected just the package failing, got %q", line)
+	}
+}
+
+// the demo has a bit of everything, and every reporter copes with it
+func TestDemo(t *testing.T) {
+	seen := map[string]bool{}
+	for _, ev := range demoEvents() {
+		seen[ev.Action] = true
+	}
+	for _, action := range []string{"start", "run", "pause", "cont", "output", "pass", "fail", "skip", "bench", "build-output", "build-fail"} {
+		if !seen[action] {
+			t.Errorf("the demo has no %q events", action)
+		}
+	}
+
+	for _, p := range []progressReporter{
+		&defaultProgress{},
+		&verboseProgress{seenFails: map[string]bool{}},
+		&quietProgress{},
+		&tinyProgress{},
+		&porcelainProgress{},
+	} {
+		var buf bytes.Buffer
+		p.setEscape("test")
+		p.setLayout(0, glyphSets["ascii"])
+		p.setOutput(&buf)
+
+		c := newCompat()
+		var ss summary
+		for _, ev := range demoEvents() {
+			ev := ev
+			if !c.normalize(&ev) {
+				continue
+			}
+			ev.prefix = demoPrefix
+			p.report(&ev)
+			ss.add(&ev)
+		}
+		p.summarize(&ss)
+
+		if ss.tests.passed != 6 || ss.tests.failed != 2 || ss.tests.skipped != 1 {
+			t.Errorf("%T: expected 6 tests to pass, 2 to fail, and 1 to skip, got %+v", p, ss.tests)
+		}
+		if ss.packages.errored != 1 || ss.packages.failed != 1 {
+			t.Errorf("%T: expected a package to fail and one to error, got %+v", p, ss.packages)
+		}
+		if buf.Len() == 0 {
+			t.Errorf("%T: said nothing", p)
+		}
+	}
+}
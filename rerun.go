@@ -0,0 +1,111 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reruns keeps track of the tests that failed, to say how to run just
+// those again. A nil reruns does nothing.
+type reruns struct {
+	pkgs  []string
+	tests map[string][]string
+	seen  map[string]bool
+}
+
+func newReruns() *reruns {
+	return &reruns{tests: map[string][]string{}, seen: map[string]bool{}}
+}
+
+func (r *reruns) add(ev *TestEvent) {
+	if r == nil || ev.Action != "fail" || !ev.isTest() || ev.Package == "" {
+		// without the package there's no saying how to run it again
+		return
+	}
+	// -run matches subtests level by level, so the top-level test is
+	// what's needed (and catches all failing subtests at once)
+	test := ev.Test
+	if idx := strings.IndexByte(test, '/'); idx > -1 {
+		test = test[:idx]
+	}
+	key := ev.Package + ":" + test
+	if r.seen[key] {
+		return
+	}
+	r.seen[key] = true
+	if _, ok := r.tests[ev.Package]; !ok {
+		r.pkgs = append(r.pkgs, ev.Package)
+	}
+	r.tests[ev.Package] = append(r.tests[ev.Package], regexp.QuoteMeta(test))
+}
+
+// commands returns a ‘go test’ command line per package with failed
+// tests, that runs just those tests (with the given extra flags).
+func (r *reruns) commands(flags []string) []string {
+	if r == nil {
+		return nil
+	}
+	var cmds []string
+	for _, pkg := range r.pkgs {
+		argv := append([]string{"go", "test"}, flags...)
+		argv = append(argv, "-run", "^("+strings.Join(r.tests[pkg], "|")+")$", pkg)
+		cmds = append(cmds, shellQuote(argv))
+	}
+	return cmds
+}
+
+// filter returns what ‘go test’ needs to run all of the failed tests in
+// one go: the ‘-run’ pattern, and the packages. A test that failed in one
+// package is also run in the others, if they have one by that name.
+func (r *reruns) filter() (string, []string) {
+	var tests []string
+	seen := map[string]bool{}
+	for _, pkg := range r.pkgs {
+		for _, test := range r.tests[pkg] {
+			if !seen[test] {
+				seen[test] = true
+				tests = append(tests, test)
+			}
+		}
+	}
+	return "^(" + strings.Join(tests, "|") + ")$", r.pkgs
+}
+
+// rerunFlags returns the flags worth keeping from the original go
+// test run when rerunning a subset of the tests.
+func rerunFlags(args []string) []string {
+	flags, _, _ := splitArgs(args)
+	var keep []string
+	for i := 0; i < len(flags); i++ {
+		name := strings.TrimLeft(flags[i], "-")
+		if idx := strings.IndexByte(name, '='); idx > -1 {
+			name = name[:idx]
+		} else if goValueFlags[name] && i+1 < len(flags) {
+			if name != "run" {
+				keep = append(keep, flags[i], flags[i+1])
+			}
+			i++
+			continue
+		}
+		if name == "run" || name == "json" {
+			continue
+		}
+		keep = append(keep, flags[i])
+	}
+	return keep
+}
+
+// silentProgress says nothing, for when all that's wanted is the
+// command to rerun the failed tests.
+type silentProgress struct {
+	escape
+	layout
+	sink
+}
+
+func (*silentProgress) report(*TestEvent)  {}
+func (*silentProgress) summarize(*summary) {}
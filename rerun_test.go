@@ -0,0 +1,27 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"reflect"
+	"testing"
+)
+
+// failed tests from an unknown package (e.g. from a test binary's output)
+// can't be run again, so don't get a command
+func TestRerunsNeedPackage(t *testing.T) {
+	r := newReruns()
+	for _, ev := range []TestEvent{
+		{Action: "fail", Test: "TestX"},
+		{Action: "fail", Package: "example.com/a", Test: "TestA/sub"},
+		{Action: "fail", Package: "example.com/a", Test: "TestA"},
+	} {
+		r.add(&ev)
+	}
+	expected := []string{"go test -count=1 -run '^(TestA)$' example.com/a"}
+	if cmds := r.commands([]string{"-count=1"}); !reflect.DeepEqual(cmds, expected) {
+		t.Errorf("expected %q, got %q", expected, cmds)
+	}
+}
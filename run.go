@@ -0,0 +1,205 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// a chain runs commands one after the other, reading their output as if
+// it were a single stream. If a command fails without having produced any
+// JSON, a FAIL line is added to the stream so it's not missed.
+type chain struct {
+	// merge the commands' stderr into the stream
+	merge  bool
+	cmds   []*exec.Cmd
+	labels []string
+
+	cmd   *exec.Cmd
+	label string
+	pipe  io.ReadCloser
+	json  bool
+	nl    bool
+	extra []byte
+}
+
+func (c *chain) add(label string, cmd *exec.Cmd) {
+	c.cmds = append(c.cmds, cmd)
+	c.labels = append(c.labels, label)
+}
+
+// next starts the next command in the chain.
+func (c *chain) next() error {
+	c.cmd, c.cmds = c.cmds[0], c.cmds[1:]
+	c.label, c.labels = c.labels[0], c.labels[1:]
+	c.json = false
+	pipe, err := c.cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if c.merge {
+		c.cmd.Stderr = c.cmd.Stdout
+	}
+	if err := c.cmd.Start(); err != nil {
+		return err
+	}
+	c.pipe = pipe
+	return nil
+}
+
+func (c *chain) failed(err error) {
+	c.extra = []byte(fmt.Sprintf("FAIL\t%s [%v]\n", c.label, err))
+	if !c.nl {
+		c.extra = append([]byte{'\n'}, c.extra...)
+	}
+}
+
+func (c *chain) Read(p []byte) (int, error) {
+	for {
+		if len(c.extra) > 0 {
+			n := copy(p, c.extra)
+			c.extra = c.extra[n:]
+			c.nl = true
+			return n, nil
+		}
+		if c.pipe == nil {
+			if len(c.cmds) == 0 {
+				return 0, io.EOF
+			}
+			if err := c.next(); err != nil {
+				c.failed(err)
+			}
+			continue
+		}
+		n, err := c.pipe.Read(p)
+		if n > 0 {
+			if bytes.Contains(p[:n], []byte(`{"`)) {
+				c.json = true
+			}
+			c.nl = p[n-1] == '\n'
+			return n, nil
+		}
+		if err == io.EOF {
+			c.pipe = nil
+			if err := c.cmd.Wait(); err != nil && !c.json {
+				c.failed(err)
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+type module struct {
+	path string
+	dir  string
+}
+
+// workspaceModules returns the modules in the current go workspace, if
+// there is one.
+func workspaceModules(ctx context.Context) ([]module, error) {
+	out, err := exec.CommandContext(ctx, "go", "env", "GOWORK").Output()
+	if err != nil {
+		return nil, err
+	}
+	if gowork := strings.TrimSpace(string(out)); gowork == "" || gowork == "off" {
+		return nil, nil
+	}
+	out, err = exec.CommandContext(ctx, "go", "list", "-m", "-f", "{{.Path}}\t{{.Dir}}").Output()
+	if err != nil {
+		return nil, err
+	}
+	var mods []module
+	for _, line := range strings.Split(string(out), "\n") {
+		idx := strings.IndexByte(line, '\t')
+		if idx < 1 {
+			continue
+		}
+		mods = append(mods, module{path: line[:idx], dir: line[idx+1:]})
+	}
+	return mods, nil
+}
+
+// notFoundRx matches the ways go has of saying the packages asked for
+// aren't there.
+var notFoundRx = regexp.MustCompile(`matched no packages|no packages to test|directory not found|no such file or directory|cannot find package|is not in std|no Go files in`)
+
+// whyFailed tries to make sense of go test failing without having run any
+// tests, from what it said that wasn't test output.
+func whyFailed(err error, said []string) string {
+	for _, line := range said {
+		if notFoundRx.MatchString(line) {
+			return "No packages matched what was asked for (see above)."
+		}
+	}
+	if len(said) > 0 {
+		return "Nothing could be tested, as it didn't build (see above)."
+	}
+	return fmt.Sprintf("go test failed without saying why (%v).", err)
+}
+
+// labelled writes what's written to it on to w a line at a time, each
+// line starting with the label.
+type labelled struct {
+	w     io.Writer
+	label string
+	// mu is as a command that's not been waited for (e.g. on being
+	// stopped early) could still be writing when flushed
+	mu  sync.Mutex
+	buf []byte
+	// said has the first lines written (without the label), for making
+	// sense of go test failing
+	said []string
+}
+
+func (l *labelled) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, p...)
+	for {
+		idx := bytes.IndexByte(l.buf, '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		l.keep(l.buf[:idx])
+		if _, err := fmt.Fprintf(l.w, "%s%s\n", l.label, l.buf[:idx]); err != nil {
+			return 0, err
+		}
+		l.buf = l.buf[idx+1:]
+	}
+}
+
+// flush writes out what's left of a last line without a newline.
+func (l *labelled) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) > 0 {
+		l.keep(l.buf)
+		fmt.Fprintf(l.w, "%s%s\n", l.label, l.buf)
+		l.buf = nil
+	}
+}
+
+func (l *labelled) keep(line []byte) {
+	if len(l.said) < 100 {
+		l.said = append(l.said, string(line)+"\n")
+	}
+}
+
+// lines returns the first lines written, as kept.
+func (l *labelled) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.said
+}
@@ -0,0 +1,31 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// what go says on a separate stderr is still there to make sense of it
+// failing
+func TestLabelledLines(t *testing.T) {
+	var buf bytes.Buffer
+	l := &labelled{w: &buf, label: "[go test] "}
+	l.Write([]byte("# example.com/a\na.go:1:1: oops\nno new"))
+	l.Write([]byte("line"))
+	l.flush()
+	if expected := "[go test] # example.com/a\n[go test] a.go:1:1: oops\n[go test] no newline\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	expected := []string{"# example.com/a\n", "a.go:1:1: oops\n", "no newline\n"}
+	if lines := l.lines(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("expected %q, got %q", expected, lines)
+	}
+	if why := whyFailed(nil, l.lines()); why != "Nothing could be tested, as it didn't build (see above)." {
+		t.Errorf("unexpected %q", why)
+	}
+}
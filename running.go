@@ -0,0 +1,70 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// running keeps track of what tests and packages have started and not
+// yet finished, so that when things hang it can say what's stuck.
+type running struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func newRunning() *running {
+	return &running{since: map[string]time.Time{}}
+}
+
+func (r *running) track(ev *TestEvent) {
+	name := ev.name()
+	if ev.Test == "" {
+		name = ev.pkg()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case ev.Action == "run" || (ev.Action == "start" && ev.Test == ""):
+		when := ev.Time
+		if when.IsZero() {
+			when = time.Now()
+		}
+		r.since[name] = when
+	case ev.Action == "error":
+		delete(r.since, ev.pkg())
+	case ev.isResult():
+		delete(r.since, name)
+	}
+}
+
+// report says what's still running, longest-running first.
+func (r *running) report(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.since) == 0 {
+		fmt.Fprintln(w, "\nNothing is running (that goctest knows of).")
+		return
+	}
+	names := make([]string, 0, len(r.since))
+	for name := range r.since {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.since[names[i]].Equal(r.since[names[j]]) {
+			return names[i] < names[j]
+		}
+		return r.since[names[i]].Before(r.since[names[j]])
+	})
+	fmt.Fprintf(w, "\nStill running (%s):\n", num(len(names)))
+	now := time.Now()
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s (for %s)\n", name, now.Sub(r.since[name]).Round(time.Second))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseShard parses a ‘--shard’ spec, e.g. ‘2/4’ for the second shard of
+// four.
+func parseShard(spec string) (m, n int, err error) {
+	idx := strings.IndexByte(spec, '/')
+	if idx > 0 {
+		m, err = strconv.Atoi(spec[:idx])
+		if err == nil {
+			n, err = strconv.Atoi(spec[idx+1:])
+		}
+	}
+	if idx < 1 || err != nil || m < 1 || n < m {
+		return 0, 0, fmt.Errorf("not of the form M/N with 1 ≤ M ≤ N")
+	}
+	return m, n, nil
+}
+
+// loadTimings reads how long each package took from a file written by
+// ‘--timings’.
+func loadTimings(path string) (map[string]float64, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var all []pkgTiming
+	if err := json.Unmarshal(buf, &all); err != nil {
+		return nil, err
+	}
+	took := make(map[string]float64, len(all))
+	for _, pt := range all {
+		took[pt.Package] = pt.Elapsed
+	}
+	return took, nil
+}
+
+// shardOf splits the packages into n shards and returns the m-th one
+// (counting from 1). The same packages are always split the same way.
+// Without timings they're dealt out in turn; with them, the slowest
+// packages are placed first, each on the shard with the least to do so
+// far, with packages the timings don't know about counting as average.
+func shardOf(pkgs []string, m, n int, took map[string]float64) []string {
+	pkgs = append([]string(nil), pkgs...)
+	sort.Strings(pkgs)
+	var shard []string
+	if len(took) == 0 {
+		for i, pkg := range pkgs {
+			if i%n == m-1 {
+				shard = append(shard, pkg)
+			}
+		}
+		return shard
+	}
+	avg := 0.0
+	for _, t := range took {
+		avg += t
+	}
+	avg /= float64(len(took))
+	weight := func(pkg string) float64 {
+		if t, ok := took[pkg]; ok {
+			return t
+		}
+		return avg
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return weight(pkgs[i]) > weight(pkgs[j])
+	})
+	load := make([]float64, n)
+	for _, pkg := range pkgs {
+		least := 0
+		for i := range load {
+			if load[i] < load[least] {
+				least = i
+			}
+		}
+		load[least] += weight(pkg)
+		if least == m-1 {
+			shard = append(shard, pkg)
+		}
+	}
+	sort.Strings(shard)
+	return shard
+}
@@ -0,0 +1,37 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"reflect"
+	"testing"
+)
+
+// every package ends up in exactly one shard, however they're split
+func TestShardOf(t *testing.T) {
+	pkgs := []string{"e", "a", "d", "b", "c", "f", "g"}
+	for _, took := range []map[string]float64{nil, {"a": 10, "b": 1, "c": 5, "z": 3}} {
+		seen := map[string]int{}
+		for m := 1; m <= 3; m++ {
+			shard := shardOf(pkgs, m, 3, took)
+			if !reflect.DeepEqual(shard, shardOf(pkgs, m, 3, took)) {
+				t.Errorf("shard %d/3 (%v) isn't the same twice", m, took)
+			}
+			for _, pkg := range shard {
+				seen[pkg]++
+			}
+		}
+		for _, pkg := range pkgs {
+			if seen[pkg] != 1 {
+				t.Errorf("with %v, %q is in %d shards", took, pkg, seen[pkg])
+			}
+		}
+	}
+	// the slowest package gets a shard to itself
+	took := map[string]float64{"a": 100, "b": 1, "c": 1, "d": 1, "e": 1, "f": 1, "g": 1}
+	if shard := shardOf(pkgs, 1, 3, took); !reflect.DeepEqual(shard, []string{"a"}) {
+		t.Errorf("expected the first shard to be just the slowest package, got %v", shard)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// testShares adds up how long each test took, to show the slowest few
+// with their share of the time all the tests took, so the few tests that
+// take most of it stand out. Tests with subtests aren't counted, as what
+// they took is mostly what their subtests took. A nil testShares does
+// nothing.
+type testShares struct {
+	took    map[string]float64
+	names   map[string]string
+	parents map[string]bool
+	tests   []string
+	total   float64
+}
+
+func newTestShares() *testShares {
+	return &testShares{
+		took:    map[string]float64{},
+		names:   map[string]string{},
+		parents: map[string]bool{},
+	}
+}
+
+func (t *testShares) add(ev *TestEvent) {
+	if t == nil || !ev.isTest() {
+		return
+	}
+	key := ev.Package + ":" + ev.Test
+	if ev.Action == "run" {
+		// a test's subtests all run before it's done
+		name := ev.Test
+		for idx := strings.LastIndexByte(name, '/'); idx > -1; idx = strings.LastIndexByte(name, '/') {
+			name = name[:idx]
+			t.parents[ev.Package+":"+name] = true
+		}
+		return
+	}
+	if !ev.isResult() || t.parents[key] {
+		return
+	}
+	if _, ok := t.took[key]; !ok {
+		t.tests = append(t.tests, key)
+		t.names[key] = ev.name()
+	}
+	// with -count, a test runs more than once
+	t.took[key] += ev.Elapsed
+	t.total += ev.Elapsed
+}
+
+// report shows the slowest few tests, and their share of the total.
+func (t *testShares) report(w io.Writer, esc *escape) {
+	if t == nil || t.total <= 0 {
+		return
+	}
+	sort.SliceStable(t.tests, func(i, j int) bool {
+		return t.took[t.tests[i]] > t.took[t.tests[j]]
+	})
+	tests := t.tests
+	if len(tests) > 10 {
+		tests = tests[:10]
+	}
+	fmt.Fprintln(w, "\nSlowest tests:")
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', 0)
+	for _, key := range tests {
+		// in thousandths, so small shares don't all look the same; the
+		// colour's last, as the escapes would throw the columns out
+		share := int(1000 * t.took[key] / t.total)
+		fmt.Fprintf(tw, "  %s\ttook: %s,\tshare: %s%.1f%%%s\n", t.names[key], secs(t.took[key]),
+			esc.rgb(colourForRatio(1000-share, 1000)), float64(share)/10, esc.endc)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,40 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bytes"
+	"testing"
+)
+
+// each test gets its share of the time all of them took, the bigger the
+// redder, with tests that have subtests left out
+func TestTestShares(t *testing.T) {
+	s := newTestShares()
+	for _, ev := range []TestEvent{
+		{Action: "run", Package: "x", Test: "TestBig"},
+		{Action: "pass", Package: "x", Test: "TestBig", Elapsed: 6},
+		{Action: "run", Package: "x", Test: "TestGroup"},
+		{Action: "run", Package: "x", Test: "TestGroup/a"},
+		{Action: "pass", Package: "x", Test: "TestGroup/a", Elapsed: 3},
+		{Action: "run", Package: "x", Test: "TestGroup/b"},
+		{Action: "fail", Package: "x", Test: "TestGroup/b", Elapsed: 1},
+		{Action: "fail", Package: "x", Test: "TestGroup", Elapsed: 4},
+		{Action: "pass", Package: "x", Elapsed: 10},
+	} {
+		s.add(&ev)
+	}
+	var buf bytes.Buffer
+	s.report(&buf, escapes[testEsc])
+	expected := `
+Slowest tests:
+  x:TestBig     took: 6s, share: #a05e0060.0%ENDC
+  x:TestGroup/a took: 3s, share: #6d920030.0%ENDC
+  x:TestGroup/b took: 1s, share: #00af0010.0%ENDC
+`
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"os"
+	"syscall"
+)
+
+// quitSignals are the signals that get goctest to say what's still
+// running, and carry on.
+var quitSignals = []os.Signal{syscall.SIGQUIT}
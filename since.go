@@ -0,0 +1,219 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// changedPackages returns the import paths of the packages (out of the
+// ones matching the given patterns) that have files that changed since
+// the given git ref.
+func changedPackages(ctx context.Context, ref string, patterns []string) ([]string, error) {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, err
+	}
+	top := strings.TrimSpace(string(out))
+	out, err = exec.CommandContext(ctx, "git", "diff", "--name-only", ref).Output()
+	if err != nil {
+		return nil, err
+	}
+	files := strings.Fields(string(out))
+	if len(files) == 0 {
+		return nil, nil
+	}
+	dirs, err := packageDirs(ctx, patterns)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []string
+	seen := map[string]bool{}
+	for _, file := range files {
+		dir := filepath.Dir(filepath.Join(top, file))
+		if pkg, ok := dirs[dir]; ok && !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs, nil
+}
+
+// packageDirs maps the directories of the packages matching the given
+// patterns to their import paths.
+func packageDirs(ctx context.Context, patterns []string) (map[string]string, error) {
+	args := append([]string{"list", "-e", "-f", "{{.Dir}}\t{{.ImportPath}}"}, patterns...)
+	out, err := exec.CommandContext(ctx, "go", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		idx := strings.IndexByte(line, '\t')
+		if idx < 1 {
+			continue
+		}
+		dirs[line[:idx]] = line[idx+1:]
+	}
+	return dirs, nil
+}
+
+// hunkRx matches the header of a hunk of a unified diff, capturing where
+// (and how many lines) it is in the new file.
+var hunkRx = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// lineRange is a range of lines of a file, first and last included.
+type lineRange struct{ first, last int }
+
+// changedTests returns the names of the tests that changed since the
+// given git ref, and the import paths of the packages they're in (out of
+// the ones matching the given patterns). If anything else in those
+// packages changed, or a change can't be pinned on a test, there's no
+// telling which tests it affects, and it says so with an error.
+func changedTests(ctx context.Context, ref string, patterns []string) (pkgs, tests []string, err error) {
+	out, err := exec.CommandContext(ctx, "git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, nil, err
+	}
+	top := strings.TrimSpace(string(out))
+	out, err = exec.CommandContext(ctx, "git", "diff", "--name-only", ref).Output()
+	if err != nil {
+		return nil, nil, err
+	}
+	files := strings.Fields(string(out))
+	if len(files) == 0 {
+		return nil, nil, nil
+	}
+	out, err = exec.CommandContext(ctx, "git", "diff", "--no-color", "--no-ext-diff", "-U0",
+		"--src-prefix=a/", "--dst-prefix=b/", ref).Output()
+	if err != nil {
+		return nil, nil, err
+	}
+	hunks, err := parseHunks(string(out))
+	if err != nil {
+		return nil, nil, err
+	}
+	dirs, err := packageDirs(ctx, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	seenPkg := map[string]bool{}
+	seenTest := map[string]bool{}
+	for _, file := range files {
+		path := filepath.Join(top, file)
+		pkg, ok := dirs[filepath.Dir(path)]
+		if !ok {
+			continue
+		}
+		if !strings.HasSuffix(file, "_test.go") {
+			return nil, nil, fmt.Errorf("%s isn't a test file", file)
+		}
+		// a removed file doesn't parse, which is as it should be
+		names, err := testsAt(path, hunks[file])
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(names) > 0 && !seenPkg[pkg] {
+			seenPkg[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+		for _, name := range names {
+			if !seenTest[name] {
+				seenTest[name] = true
+				tests = append(tests, name)
+			}
+		}
+	}
+	return pkgs, tests, nil
+}
+
+// parseHunks works out, from a unified diff without context, what lines
+// of each file changed. Lines that were only removed are put down to the
+// line before them.
+func parseHunks(diff string) (map[string][]lineRange, error) {
+	hunks := map[string][]lineRange{}
+	file := ""
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(nil, maxLineLength)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "--- ") {
+			file = ""
+			continue
+		}
+		if strings.HasPrefix(line, "+++ b/") {
+			file = line[6:]
+			continue
+		}
+		m := hunkRx.FindStringSubmatch(line)
+		if m == nil || file == "" {
+			continue
+		}
+		first, _ := strconv.Atoi(m[1])
+		n := 1
+		if m[2] != "" {
+			n, _ = strconv.Atoi(m[2])
+		}
+		last := first + n - 1
+		if n == 0 {
+			last = first
+		}
+		hunks[file] = append(hunks[file], lineRange{first, last})
+	}
+	return hunks, scanner.Err()
+}
+
+// testsAt returns the names of the tests the given ranges of lines of the
+// test file are in.
+func testsAt(path string, ranges []lineRange) ([]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range ranges {
+		var found *ast.FuncDecl
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			start := fd.Pos()
+			if fd.Doc != nil {
+				start = fd.Doc.Pos()
+			}
+			if fset.Position(start).Line <= r.first && r.last <= fset.Position(fd.End()).Line {
+				found = fd
+				break
+			}
+		}
+		if found == nil || found.Recv != nil || !isTestName(found.Name.Name) {
+			return nil, fmt.Errorf("%s:%d isn't in a test", filepath.Base(path), r.first)
+		}
+		names = append(names, found.Name.Name)
+	}
+	return names, nil
+}
+
+// isTestName returns whether the function is one go test would run as a
+// test, i.e. TestXxx where Xxx doesn't start with a lower case letter.
+func isTestName(name string) bool {
+	if !strings.HasPrefix(name, "Test") {
+		return false
+	}
+	rest := strings.TrimPrefix(name, "Test")
+	return rest == "" || !(rest[0] >= 'a' && rest[0] <= 'z')
+}
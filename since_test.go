@@ -0,0 +1,87 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const sinceDiff = `diff --git a/a/a_test.go b/a/a_test.go
+index 1111111..2222222 100644
+--- a/a/a_test.go
++++ b/a/a_test.go
+@@ -7 +7 @@ func TestOne(t *testing.T) {
+-	t.Log("one")
++	t.Log("un")
+@@ -12,0 +13,2 @@ func TestTwo(t *testing.T) {
++	t.Log("deux")
++	t.Log("zwei")
+@@ -20,2 +21,0 @@ func TestTwo(t *testing.T) {
+-	t.Log("old")
+-	t.Log("older")
+diff --git a/b/gone_test.go b/b/gone_test.go
+deleted file mode 100644
+--- a/b/gone_test.go
++++ /dev/null
+@@ -1,3 +0,0 @@
+-package b
+-
+-func TestGone(t *testing.T) {}
+`
+
+func TestParseHunks(t *testing.T) {
+	hunks, err := parseHunks(sinceDiff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]lineRange{
+		"a/a_test.go": {{7, 7}, {13, 14}, {21, 21}},
+	}
+	if !reflect.DeepEqual(hunks, expected) {
+		t.Errorf("expected %v, got %v", expected, hunks)
+	}
+}
+
+const sinceSource = `package a
+
+import "testing"
+
+// TestOne is one.
+func TestOne(t *testing.T) {
+	t.Log("un")
+}
+
+func TestTwo(t *testing.T) {
+	t.Log("two")
+}
+
+func testHelper() {}
+
+func Testing() {}
+`
+
+func TestTestsAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a_test.go")
+	if err := ioutil.WriteFile(path, []byte(sinceSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	names, err := testsAt(path, []lineRange{{5, 5}, {7, 7}, {11, 11}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"TestOne", "TestOne", "TestTwo"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %q, got %q", expected, names)
+	}
+	// outside of any test (the imports, a helper, and something that
+	// isn't a test for all it's called Test…)
+	for _, r := range []lineRange{{3, 3}, {14, 14}, {16, 16}, {8, 10}} {
+		if _, err := testsAt(path, []lineRange{r}); err == nil {
+			t.Errorf("expected lines %d–%d to not be in a test", r.first, r.last)
+		}
+	}
+}
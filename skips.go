@@ -0,0 +1,101 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// logRx matches a line logged by a test, e.g. via t.Skip.
+var logRx = regexp.MustCompile(`^\s+[^\s:]+\.go:\d+: (.*)$`)
+
+// skipReasons tallies why tests were skipped. A nil skipReasons does
+// nothing.
+type skipReasons struct {
+	count   map[string]int
+	reasons []string
+	total   int
+}
+
+func newSkipReasons() *skipReasons {
+	return &skipReasons{count: map[string]int{}}
+}
+
+// skipReason works out why a test skipped from its output, which is the
+// last thing it logged before skipping.
+func skipReason(output []string) string {
+	for i := len(output) - 1; i >= 0; i-- {
+		if m := logRx.FindStringSubmatch(strings.TrimRight(output[i], "\n")); m != nil {
+			return m[1]
+		}
+	}
+	return "(no reason given)"
+}
+
+func (s *skipReasons) add(output []string) {
+	if s == nil {
+		return
+	}
+	reason := skipReason(output)
+	if s.count[reason] == 0 {
+		s.reasons = append(s.reasons, reason)
+	}
+	s.count[reason]++
+	s.total++
+}
+
+func (s *skipReasons) report(w io.Writer, esc *escape) {
+	if s == nil || s.total == 0 {
+		return
+	}
+	sort.SliceStable(s.reasons, func(i, j int) bool {
+		return s.count[s.reasons[i]] > s.count[s.reasons[j]]
+	})
+	tally := make([]string, len(s.reasons))
+	for i, reason := range s.reasons {
+		tally[i] = fmt.Sprintf("‘%s’ ×%d", reason, s.count[reason])
+	}
+	fmt.Fprintf(w, "\n%d %sskipped%s: %s\n", s.total, esc.skip, esc.endc, strings.Join(tally, ", "))
+}
+
+// shortRx matches the reason given by a test skipped because of -short.
+var shortRx = regexp.MustCompile(`(?i)\bshort\b`)
+
+// skipGate keeps track of the skipped tests that ‘--fail-on-skip’ says
+// should fail the run. A nil skipGate does nothing.
+type skipGate struct {
+	// shortOK leaves out tests skipped because of -short
+	shortOK bool
+	names   []string
+}
+
+func (g *skipGate) add(name string, output []string) {
+	if g == nil {
+		return
+	}
+	if g.shortOK && shortRx.MatchString(skipReason(output)) {
+		return
+	}
+	g.names = append(g.names, name)
+}
+
+func (g *skipGate) failed() bool {
+	return g != nil && len(g.names) > 0
+}
+
+func (g *skipGate) report(w io.Writer, esc *escape) {
+	if !g.failed() {
+		return
+	}
+	fmt.Fprintf(w, "\n%s%s skipped, which ‘--fail-on-skip’ counts as failing:%s\n",
+		esc.fail, gn("test", "tests")(len(g.names)), esc.endc)
+	for _, name := range g.names {
+		fmt.Fprintf(w, "  %s%s%s\n", esc.skip, name, esc.endc)
+	}
+}
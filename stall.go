@@ -0,0 +1,80 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// a stallWatch complains when go test has gone quiet for too long, and
+// can put a stop to it. A nil stallWatch does nothing.
+type stallWatch struct {
+	after  time.Duration
+	kill   bool
+	poked  chan struct{}
+	killed int32
+}
+
+func newStallWatch(after time.Duration, kill bool) *stallWatch {
+	if after <= 0 {
+		return nil
+	}
+	return &stallWatch{after: after, kill: kill, poked: make(chan struct{}, 1)}
+}
+
+// start watching, until the context is done.
+func (w *stallWatch) start(ctx context.Context, cancel context.CancelFunc, clk *clock) {
+	if w == nil {
+		return
+	}
+	go func() {
+		timer := time.NewTimer(w.after)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.poked:
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(w.after)
+			case <-timer.C:
+				// said once, until things get going again
+				clk.Lock()
+				fmt.Fprintf(os.Stderr, "No test activity for %s; possibly a hang, or a slow build.\n", w.after)
+				clk.Unlock()
+				if w.kill {
+					atomic.StoreInt32(&w.killed, 1)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+}
+
+// poke says something happened.
+func (w *stallWatch) poke() {
+	if w == nil {
+		return
+	}
+	select {
+	case w.poked <- struct{}{}:
+	default:
+	}
+}
+
+// stopped says whether the watch put a stop to the run.
+func (w *stallWatch) stopped() bool {
+	return w != nil && atomic.LoadInt32(&w.killed) == 1
+}
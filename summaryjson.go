@@ -0,0 +1,113 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+)
+
+// sumsJSON is how ‘--summary-json’ writes out a set of sums.
+type sumsJSON struct {
+	Total   int `json:"total"`
+	Passed  int `json:"passed"`
+	Failed  int `json:"failed"`
+	Errored int `json:"errored"`
+	Skipped int `json:"skipped"`
+	XFailed int `json:"xfailed"`
+	XPassed int `json:"xpassed"`
+	Flaky   int `json:"flaky"`
+	Cached  int `json:"cached"`
+}
+
+func (s *sums) toJSON() sumsJSON {
+	return sumsJSON{
+		Total:   s.total,
+		Passed:  s.passed,
+		Failed:  s.failed,
+		Errored: s.errored,
+		Skipped: s.skipped,
+		XFailed: s.xfailed,
+		XPassed: s.xpassed,
+		Flaky:   s.flaky,
+		Cached:  s.cached,
+	}
+}
+
+func (s *sums) addJSON(j sumsJSON) {
+	s.total += j.Total
+	s.passed += j.Passed
+	s.failed += j.Failed
+	s.errored += j.Errored
+	s.skipped += j.Skipped
+	s.xfailed += j.XFailed
+	s.xpassed += j.XPassed
+	s.flaky += j.Flaky
+	s.cached += j.Cached
+}
+
+// summaryJSON is what ‘--summary-json’ writes: the counts the summary is
+// made from, and enough about the timing to add runs together.
+type summaryJSON struct {
+	Tests    sumsJSON  `json:"tests"`
+	Packages sumsJSON  `json:"packages"`
+	First    time.Time `json:"first"`
+	Last     time.Time `json:"last"`
+	// Busy is how long the packages took, all added up, and Slowest
+	// how long the slowest one took, in seconds
+	Busy    float64 `json:"busy"`
+	Slowest float64 `json:"slowest"`
+	Label   string  `json:"label,omitempty"`
+	// Excused is how many of the failed packages failed only for their
+	// flaky tests, which doesn't fail the run
+	Excused int `json:"excused,omitempty"`
+}
+
+func writeSummaryJSON(path string, ss *summary, label string) error {
+	buf, err := json.MarshalIndent(summaryJSON{
+		Tests:    ss.tests.toJSON(),
+		Packages: ss.packages.toJSON(),
+		First:    ss.first,
+		Last:     ss.last,
+		Busy:     ss.busy,
+		Slowest:  ss.slowest,
+		Label:    label,
+		Excused:  ss.excused,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
+
+// mergeSummaries adds up what the given files written by ‘--summary-json’
+// say, e.g. from the shards of a run, into the summary.
+func mergeSummaries(paths []string, ss *summary) error {
+	for _, path := range paths {
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var sj summaryJSON
+		if err := json.Unmarshal(buf, &sj); err != nil {
+			return err
+		}
+		ss.tests.addJSON(sj.Tests)
+		ss.packages.addJSON(sj.Packages)
+		if !sj.First.IsZero() && (ss.first.IsZero() || sj.First.Before(ss.first)) {
+			ss.first = sj.First
+		}
+		if sj.Last.After(ss.last) {
+			ss.last = sj.Last
+		}
+		ss.busy += sj.Busy
+		ss.excused += sj.Excused
+		if sj.Slowest > ss.slowest {
+			ss.slowest = sj.Slowest
+		}
+	}
+	return nil
+}
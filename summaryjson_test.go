@@ -0,0 +1,46 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// a package that failed only for its flaky tests doesn't fail the run,
+// merged or not
+func TestMergeExcused(t *testing.T) {
+	var shard1, shard2 summary
+	flaked := newFlakes()
+	for _, ev := range []TestEvent{
+		{Action: "fail", Package: "example.com/a", Test: "TestA"},
+		{Action: "pass", Package: "example.com/a", Test: "TestA"},
+		{Action: "fail", Package: "example.com/a"},
+	} {
+		flaked.check(&ev, &shard1)
+		shard1.add(&ev)
+	}
+	shard2.add(&TestEvent{Action: "pass", Package: "example.com/b", Test: "TestB"})
+	shard2.add(&TestEvent{Action: "pass", Package: "example.com/b"})
+	dir := t.TempDir()
+	var paths []string
+	for i, ss := range []*summary{&shard1, &shard2} {
+		path := filepath.Join(dir, []string{"1.json", "2.json"}[i])
+		if err := writeSummaryJSON(path, ss, ""); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	var merged summary
+	if err := mergeSummaries(paths, &merged); err != nil {
+		t.Fatal(err)
+	}
+	if merged.packages.failed != 1 || merged.excused != 1 {
+		t.Errorf("expected the one failed package to be excused, got %d failed and %d excused", merged.packages.failed, merged.excused)
+	}
+	if status := exitStatus(&merged, nil); status != exitStatus(&shard1, nil) || status != 0 {
+		t.Errorf("expected the merged run to exit as the shards did (0), got %d", status)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// escRx matches a terminal escape sequence: a CSI one (e.g. a colour),
+// or an OSC one (e.g. a hyperlink).
+var escRx = regexp.MustCompile("\033(?:\\[[0-9;?]*[A-Za-z]|\\][^\033\a]*(?:\a|\033\\\\))")
+
+// stripEscapes hands back what's read from r, line by line, with any
+// escape sequences taken out.
+func stripEscapes(r io.Reader) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		scanner := newScanner(r)
+		for scanner.Scan() {
+			line := append(escRx.ReplaceAll(scanner.Bytes(), nil), '\n')
+			if _, err := pw.Write(line); err != nil {
+				return
+			}
+		}
+		pw.CloseWithError(scanner.Err())
+	}()
+	return pr
+}
+
+// isTerminal returns whether the given file looks like a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// termWidth returns the width of the terminal the given file is
+// attached to, or 0 if it isn't one or the width can't be figured out.
+func termWidth(f *os.File) int {
+	if !isTerminal(f) {
+		return 0
+	}
+	if w := ttyWidth(f); w > 0 {
+		return w
+	}
+	w, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	if w < 0 {
+		return 0
+	}
+	return w
+}
+
+// ellipsize shortens s to the given width (in runes) by replacing its
+// middle with an ellipsis. A width of 0 or less means no limit.
+func ellipsize(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	r := []rune(s)
+	head := (width - 1) / 2
+	tail := width - 1 - head
+	return string(r[:head]) + "…" + string(r[len(r)-tail:])
+}
+
+// a layout knows how wide the output can be, if anything, and what
+// glyphs to use.
+type layout struct {
+	width  int
+	glyphs *glyphSet
+}
+
+func (l *layout) setLayout(width int, glyphs *glyphSet) {
+	l.width = width
+	l.glyphs = glyphs
+}
+
+func (l *layout) glyph() *glyphSet {
+	if l.glyphs == nil {
+		return glyphSets["default"]
+	}
+	return l.glyphs
+}
+
+// fit shortens s so that it fits in what's left of a line after a
+// glyph and a space, and extra more columns, have been taken up.
+func (l *layout) fit(s string, extra int) string {
+	if l.width <= 0 {
+		return s
+	}
+	room := l.width - l.glyph().width - 1 - extra
+	if room < 1 {
+		room = 1
+	}
+	return ellipsize(s, room)
+}
+
+// columnize lays cells out in as many columns as fit in width (going
+// down each column first, like ‘ls’ does), with gap columns between
+// them and the given extra columns taken up in front of each cell. It
+// returns the cells of each row, padded so they line up.
+func columnize(cells []string, width, extra, gap int) [][]string {
+	if len(cells) == 0 {
+		return nil
+	}
+	wide := 0
+	for _, cell := range cells {
+		if n := utf8.RuneCountInString(cell); n > wide {
+			wide = n
+		}
+	}
+	cols := 1
+	if width > 0 {
+		cols = (width + gap) / (extra + wide + gap)
+	}
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (len(cells) + cols - 1) / cols
+	grid := make([][]string, rows)
+	for i, cell := range cells {
+		row := i % rows
+		if i+rows < len(cells) {
+			cell += strings.Repeat(" ", wide-utf8.RuneCountInString(cell)+gap)
+		}
+		grid[row] = append(grid[row], cell)
+	}
+	return grid
+}
+
+// wrap breaks the line at spaces so that it fits in the given width,
+// indenting what wraps a bit more than the line itself is. Escape
+// sequences don't take up any room. Lines that look like they're part of
+// a diff are left alone, so they still line up.
+func wrap(line string, width int) string {
+	text := strings.TrimRight(line, "\n")
+	nl := line[len(text):]
+	plain := escRx.ReplaceAllString(text, "")
+	if width <= 0 || utf8.RuneCountInString(plain) <= width || diffRx.MatchString(plain) {
+		return line
+	}
+	lead := plain[:len(plain)-len(strings.TrimLeft(plain, " \t"))]
+	indent := lead + "    "
+	leadCols, indentCols := advance(0, lead), advance(0, indent)
+	if indentCols >= width/2 {
+		return line
+	}
+
+	var out strings.Builder
+	col := 0
+	// where in out the last space is, and the column just past it
+	brk, brkCol := -1, 0
+	for i := 0; i < len(text); {
+		if loc := escRx.FindStringIndex(text[i:]); loc != nil && loc[0] == 0 {
+			out.WriteString(text[i : i+loc[1]])
+			i += loc[1]
+			continue
+		}
+		r, n := utf8.DecodeRuneInString(text[i:])
+		i += n
+		if r == ' ' && col >= leadCols {
+			brk, brkCol = out.Len(), col+1
+		}
+		out.WriteRune(r)
+		col = advance(col, string(r))
+		if col > width && brk > -1 {
+			rest := out.String()[brk+1:]
+			head := out.String()[:brk]
+			out.Reset()
+			out.WriteString(head + "\n" + indent + rest)
+			col = indentCols + col - brkCol
+			brk = -1
+		}
+	}
+	return out.String() + nl
+}
+
+// advance returns the column the cursor ends up in after writing s from
+// column col (tabs go to the next multiple of 8).
+func advance(col int, s string) int {
+	for _, r := range s {
+		if r == '\t' {
+			col = (col/8 + 1) * 8
+		} else {
+			col++
+		}
+	}
+	return col
+}
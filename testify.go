@@ -0,0 +1,118 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// the file:line a test logged the failure from, with nothing after it
+	// as testify starts its report on the next line
+	testifyAtRx = regexp.MustCompile(`^(\s*)(\S+\.go:\d+):\s*$`)
+	// a field of testify's report, e.g. ‘Error:’
+	testifyFieldRx = regexp.MustCompile(`^\s*\t([A-Z][A-Za-z ]*):\s*\t(.*?)\s*$`)
+	// more of the field before it
+	testifyMoreRx = regexp.MustCompile(`^\s*\t\s+\t(.*?)\s*$`)
+	// what testify says was expected, and what it got instead
+	testifyWantRx = regexp.MustCompile(`^expected\s*:`)
+	testifyGotRx  = regexp.MustCompile(`^actual\s*:`)
+)
+
+type testifyField struct {
+	name  string
+	lines []string
+}
+
+// collapseTestify boils the reports of testify's failed assertions down
+// to what went wrong: the error, with what was expected and what was
+// got made to stand out, and any messages. The trace (that's just where
+// it was logged from) and the test's name are left out. Anything that
+// doesn't look like one of testify's reports is left be.
+func collapseTestify(lines []string, esc *escape) []string {
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		m := testifyAtRx.FindStringSubmatch(lines[i])
+		if m == nil || i+1 >= len(lines) {
+			out = append(out, lines[i])
+			continue
+		}
+		if f := testifyFieldRx.FindStringSubmatch(lines[i+1]); f == nil || f[1] != "Error Trace" {
+			out = append(out, lines[i])
+			continue
+		}
+		indent, at := m[1], m[2]
+		var fields []testifyField
+		j := i + 1
+		for ; j < len(lines); j++ {
+			if f := testifyFieldRx.FindStringSubmatch(lines[j]); f != nil {
+				fields = append(fields, testifyField{name: f[1], lines: []string{f[2]}})
+			} else if f := testifyMoreRx.FindStringSubmatch(lines[j]); f != nil {
+				fields[len(fields)-1].lines = append(fields[len(fields)-1].lines, f[1])
+			} else {
+				break
+			}
+		}
+		if hasError(fields) {
+			out = append(out, renderTestify(indent, at, fields, esc)...)
+		} else {
+			out = append(out, lines[i:j]...)
+		}
+		i = j - 1
+	}
+	return out
+}
+
+func hasError(fields []testifyField) bool {
+	for _, f := range fields {
+		if f.name == "Error" {
+			return true
+		}
+	}
+	return false
+}
+
+func renderTestify(indent, at string, fields []testifyField, esc *escape) []string {
+	var out []string
+	more := indent + "    "
+	for _, f := range fields {
+		switch f.name {
+		case "Error Trace", "Test":
+			continue
+		case "Error":
+			out = append(out, indent+at+": "+esc.em(f.lines[0])+"\n")
+			inDiff := false
+			for _, line := range f.lines[1:] {
+				colour := ""
+				switch {
+				case line == "Diff:":
+					inDiff = true
+				case testifyWantRx.MatchString(line):
+					colour = esc.pass
+				case testifyGotRx.MatchString(line):
+					colour = esc.fail
+				case inDiff && (strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++")):
+					// the diff's header
+				case inDiff && strings.HasPrefix(line, "-"):
+					// in testify's diffs ‘-’ is what was expected
+					colour = esc.pass
+				case inDiff && strings.HasPrefix(line, "+"):
+					colour = esc.fail
+				}
+				if colour != "" {
+					line = colour + line + esc.endc
+				}
+				out = append(out, more+line+"\n")
+			}
+		default:
+			out = append(out, more+f.name+": "+f.lines[0]+"\n")
+			for _, line := range f.lines[1:] {
+				out = append(out, more+"  "+line+"\n")
+			}
+		}
+	}
+	return out
+}
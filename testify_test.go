@@ -0,0 +1,40 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollapseTestify(t *testing.T) {
+	output := []string{
+		"=== RUN   TestSum\n",
+		"    sum_test.go:12: \n",
+		"        \tError Trace:\t/home/me/sum/sum_test.go:12\n",
+		"        \tError:      \tNot equal: \n",
+		"        \t            \texpected: 6\n",
+		"        \t            \tactual  : 5\n",
+		"        \tTest:       \tTestSum\n",
+		"        \tMessages:   \tsumming 1, 2, 3\n",
+		"    sum_test.go:13: \n",
+		"        \tnot testify at all\n",
+		"--- FAIL: TestSum (0.00s)\n",
+	}
+	expected := []string{
+		"=== RUN   TestSum\n",
+		"    sum_test.go:12: *Not equal:*\n",
+		"        PASSexpected: 6ENDC\n",
+		"        FAILactual  : 5ENDC\n",
+		"        Messages: summing 1, 2, 3\n",
+		"    sum_test.go:13: \n",
+		"        \tnot testify at all\n",
+		"--- FAIL: TestSum (0.00s)\n",
+	}
+	got := collapseTestify(output, escapes[testEsc])
+	if strings.Join(got, "") != strings.Join(expected, "") {
+		t.Errorf("got:\n%s\nexpected:\n%s", strings.Join(got, ""), strings.Join(expected, ""))
+	}
+}
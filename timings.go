@@ -0,0 +1,69 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+)
+
+// a pkgTiming is what ‘--timings’ writes out for each package: how long
+// its tests took, all told, and how many there were. It's meant for
+// tools that split packages between CI shards, so it's kept to that.
+type pkgTiming struct {
+	Package string  `json:"package"`
+	Elapsed float64 `json:"elapsed"`
+	Tests   int     `json:"tests"`
+}
+
+// timings keeps track of how long each package took, and how many tests
+// it had. A nil timings does nothing.
+type timings struct {
+	pkgs map[string]*pkgTiming
+}
+
+func newTimings() *timings {
+	return &timings{pkgs: map[string]*pkgTiming{}}
+}
+
+func (t *timings) add(ev *TestEvent, ss *summary) {
+	if t == nil || ev.Package == "" || !ev.isResult() {
+		return
+	}
+	if ev.Test != "" && (!ev.isTest() || ss.isParent(ev)) {
+		return
+	}
+	pt, ok := t.pkgs[ev.Package]
+	if !ok {
+		pt = &pkgTiming{Package: ev.Package}
+		t.pkgs[ev.Package] = pt
+	}
+	if ev.Test == "" {
+		pt.Elapsed += ev.Elapsed
+	} else {
+		pt.Tests++
+	}
+}
+
+// write writes the timings to the given file as a JSON array, slowest
+// package first.
+func (t *timings) write(path string) error {
+	all := make([]*pkgTiming, 0, len(t.pkgs))
+	for _, pt := range t.pkgs {
+		all = append(all, pt)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Elapsed == all[j].Elapsed {
+			return all[i].Package < all[j].Package
+		}
+		return all[i].Elapsed > all[j].Elapsed
+	})
+	buf, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(buf, '\n'), 0644)
+}
@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"runtime/debug"
+)
+
+// vcsInfo returns what the go tool embedded about the revision goctest
+// was built from: the revision, when it was committed, and whether
+// there were changes on top of it.
+func vcsInfo(bi *debug.BuildInfo) (rev, when string, dirty bool) {
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.time":
+			when = s.Value
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	return rev, when, dirty
+}
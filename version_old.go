@@ -0,0 +1,18 @@
+//go:build !go1.18
+// +build !go1.18
+
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"runtime/debug"
+)
+
+// vcsInfo returns nothing, as before go1.18 the go tool didn't embed
+// anything about the revision goctest was built from.
+func vcsInfo(bi *debug.BuildInfo) (rev, when string, dirty bool) {
+	return "", "", false
+}
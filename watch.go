@@ -0,0 +1,211 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileStamp is what's looked at to tell whether a file changed.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// watcher keeps an eye on the files under a directory that go test
+// cares about, by looking at all of them every so often.
+type watcher struct {
+	root     string
+	poll     time.Duration
+	debounce time.Duration
+	stamps   map[string]fileStamp
+}
+
+func newWatcher(root string, poll, debounce time.Duration) *watcher {
+	w := &watcher{root: root, poll: poll, debounce: debounce}
+	w.stamps = w.scan()
+	return w
+}
+
+// watched says whether a change to the file could change what the tests
+// say: Go files, the module's files, and anything in a testdata directory.
+func watched(path string) bool {
+	switch filepath.Base(path) {
+	case "go.mod", "go.sum", "go.work":
+		return true
+	}
+	if strings.HasSuffix(path, ".go") {
+		return true
+	}
+	return strings.Contains(string(filepath.Separator)+path, string(filepath.Separator)+"testdata"+string(filepath.Separator))
+}
+
+func (w *watcher) scan() map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	filepath.Walk(w.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// it went away while looking; the next look will tell
+			return nil
+		}
+		name := info.Name()
+		if path != w.root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			// go ignores these too (and editors hide their temp files so)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && watched(path) {
+			stamps[path] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// changed looks at the files again, and says whether any of them changed
+// (or came or went) since the last look.
+func (w *watcher) changed() bool {
+	stamps := w.scan()
+	same := len(stamps) == len(w.stamps)
+	if same {
+		for path, stamp := range stamps {
+			if old, ok := w.stamps[path]; !ok || old.size != stamp.size || !old.modTime.Equal(stamp.modTime) {
+				same = false
+				break
+			}
+		}
+	}
+	w.stamps = stamps
+	return !same
+}
+
+// wait waits for the files to change and then stay put for the debounce
+// time, so that a save that takes several writes (as some editors do)
+// only gets one run. It says false if the context was done first.
+func (w *watcher) wait(ctx context.Context) bool {
+	ticker := time.NewTicker(w.poll)
+	defer ticker.Stop()
+	timer := time.NewTimer(w.debounce)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+			if w.changed() {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(w.debounce)
+			}
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// watch runs goctest (this very one) over and over, as the code changes,
+// until interrupted, and returns how the last run went.
+func watch(ctx context.Context, opts *options) int {
+	if opts.fromStdin || opts.sniff || opts.compiled != "" || opts.gobFile != "" || opts.demo || opts.merge || opts.dryRun {
+		log.Fatal("The flag ‘--watch’ needs goctest to run go test itself.")
+	}
+	poll, err := time.ParseDuration(opts.poll)
+	if err != nil || poll <= 0 {
+		log.Fatalf("The flag ‘--poll’ needs a duration, e.g. 1s, not %q.", opts.poll)
+	}
+	debounce, err := time.ParseDuration(opts.debounce)
+	if err != nil || debounce < 0 {
+		log.Fatalf("The flag ‘--debounce’ needs a duration, e.g. 300ms, not %q.", opts.debounce)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("Unable to find goctest itself to run it again: %v", err)
+	}
+	own := append([]string{}, opts.ownArgs...)
+	failsFile := opts.failsJSONL
+	if opts.watchFailedFirst && failsFile == "" {
+		f, err := ioutil.TempFile("", "goctest-fails-*.jsonl")
+		if err != nil {
+			log.Fatalf("Unable to make a file to keep the failed tests in: %v", err)
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		failsFile = f.Name()
+		own = append(own, "--fails-jsonl", failsFile)
+	}
+	argv := append(append(own[:len(own):len(own)], "--"), opts.goArgs...)
+
+	w := newWatcher(".", poll, debounce)
+	status := runSelf(self, argv)
+	for {
+		log.Print("Watching for changes (^C to stop).")
+		if !w.wait(ctx) {
+			return status
+		}
+		if opts.watchFailedFirst && status != 0 {
+			// if the file isn't there (or is off), the run didn't get
+			// as far as saying what failed, and it's all run anyway
+			failed, _ := readFailsJSONL(failsFile)
+			if first := failedFirstArgs(own, opts.goArgs, failed); first != nil {
+				log.Print("First, the tests that failed last time:")
+				runSelf(self, first)
+				if ctx.Err() != nil {
+					return status
+				}
+				log.Print("And now, all of them:")
+			}
+		}
+		status = runSelf(self, argv)
+	}
+}
+
+// failedFirstArgs returns the arguments for goctest to run just the tests
+// that failed, given its own arguments and the ones for go test to run
+// them all; or nil if there's none to run.
+func failedFirstArgs(own, goArgs []string, failed []failedTest) []string {
+	r := newReruns()
+	for _, ft := range failed {
+		r.add(&TestEvent{Action: "fail", Package: ft.Package, Test: ft.Test})
+	}
+	if len(r.pkgs) == 0 {
+		return nil
+	}
+	pattern, pkgs := r.filter()
+	_, _, rest := splitArgs(goArgs)
+	argv := append(own[:len(own):len(own)], "--")
+	argv = append(argv, rerunFlags(goArgs)...)
+	argv = append(argv, "-run", pattern)
+	argv = append(argv, pkgs...)
+	return append(argv, rest...)
+}
+
+// runSelf runs goctest with the given arguments, and returns its exit
+// status.
+func runSelf(self string, argv []string) int {
+	cmd := exec.Command(self, argv...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := cmd.Run()
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	log.Printf("Running goctest again didn't go well: %v", err)
+	return 1
+}
@@ -0,0 +1,114 @@
+package main
+
+// © 2021 John Lenton
+// MIT licensed.
+// from https://github.com/chipaca/goctest
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcherChanged(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("foo.go", "package foo")
+	write("testdata/golden.txt", "hello")
+	w := newWatcher(dir, time.Millisecond, 0)
+	if w.changed() {
+		t.Fatal("changed without anything changing")
+	}
+	for _, tc := range []struct {
+		name    string
+		changed bool
+	}{
+		{"foo.go", true},
+		{"bar_test.go", true},
+		{"go.mod", true},
+		{"testdata/golden.txt", true},
+		{"sub/testdata/more/input", true},
+		{"notes.txt", false},
+		{"summary.json", false},
+		{".#foo.go", false},
+		{".git/index.go", false},
+		{"_old/foo.go", false},
+	} {
+		write(tc.name, strings.Repeat("x", len(w.stamps)+1))
+		if changed := w.changed(); changed != tc.changed {
+			t.Errorf("%s: expected changed to be %t, got %t", tc.name, tc.changed, changed)
+		}
+	}
+	os.Remove(filepath.Join(dir, "foo.go"))
+	if !w.changed() {
+		t.Error("a file going away should count as a change")
+	}
+}
+
+// a save done in several steps gets the one run
+func TestWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "foo.go")
+	w := newWatcher(dir, 5*time.Millisecond, 300*time.Millisecond)
+	go func() {
+		for i := 0; i < 5; i++ {
+			ioutil.WriteFile(path, []byte(strings.Repeat("x", i+1)), 0644)
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if !w.wait(ctx) {
+		t.Fatal("didn't see the changes")
+	}
+	if w.changed() {
+		t.Error("ran before the saving was done")
+	}
+
+	cancel()
+	if w.wait(ctx) {
+		t.Error("waiting after being cancelled should say so")
+	}
+}
+
+func TestFailedFirstArgs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fails.jsonl")
+	if err := writeFailsJSONL(path, []failedTest{
+		{Package: "example.com/a", Test: "TestA/sub"},
+		{Package: "example.com/a", Test: "TestA"},
+		{Package: "example.com/b", Test: "TestB"},
+		{Package: "example.com/c", Test: "TestA"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	failed, err := readFailsJSONL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := []string{"--tiny"}
+	goArgs := []string{"-count=1", "-run", "TestX", "./...", "-args", "-update"}
+	expected := []string{"--tiny", "--", "-count=1", "-run", "^(TestA|TestB)$", "example.com/a", "example.com/b", "example.com/c", "-args", "-update"}
+	if argv := failedFirstArgs(own, goArgs, failed); !reflect.DeepEqual(argv, expected) {
+		t.Errorf("expected %q, got %q", expected, argv)
+	}
+	if !reflect.DeepEqual(own, []string{"--tiny"}) {
+		t.Errorf("own arguments got changed: %q", own)
+	}
+
+	if argv := failedFirstArgs(own, goArgs, nil); argv != nil {
+		t.Errorf("nothing failed, but got %q", argv)
+	}
+}